@@ -0,0 +1,151 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package batch provides Shared Key authorization of Azure Batch service REST
+// requests.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/batchservice/authenticate-requests-to-the-azure-batch-service
+package batch
+
+import (
+	// Standard Library Imports
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/internal/canonical"
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderDate          = "Date"
+	HeaderOCPDate       = "ocp-date"
+
+	canonicalizedHeaderPrefix = "ocp-"
+)
+
+var (
+	ErrAccountNameEmpty = errors.New("batch account name must not be empty")
+	ErrSignerRequired   = errors.New("a signer must be provided to sign requests")
+	ErrDecodingKey      = errors.New("error decoding batch account key, must be base64 encoded")
+)
+
+// Signer authorizes Azure Batch service REST requests with Shared Key.
+type Signer struct {
+	accountName string
+	signer      crypto.Signer
+	now         func() time.Time
+}
+
+// NewSigner returns a Shared Key request signer for the Batch account, where
+// signing is delegated to the provided signer, for example, a crypto.Keyring
+// holding the primary and secondary account keys.
+func NewSigner(accountName string, signer crypto.Signer) (*Signer, error) {
+	if strings.TrimSpace(accountName) == "" {
+		return nil, ErrAccountNameEmpty
+	}
+
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	return &Signer{
+		accountName: accountName,
+		signer:      signer,
+		now:         time.Now,
+	}, nil
+}
+
+// NewSignerFromKey returns a Shared Key request signer for the Batch account
+// which signs with the base64 encoded account key.
+func NewSignerFromKey(accountName string, accountKey string) (*Signer, error) {
+	key, err := crypto.DecodeKey(accountKey)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	s, err := NewSigner(accountName, signer)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close destroys the Batch account key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// SignRequest authorizes the request in place, setting the ocp-date header if
+// neither it nor the Date header have been provided, then setting the
+// Authorization header. The request's context is passed through to the
+// signer.
+func (s *Signer) SignRequest(req *http.Request) error {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	if req.Header.Get(HeaderOCPDate) == "" && req.Header.Get(HeaderDate) == "" {
+		req.Header.Set(HeaderOCPDate, aztime.ToRFC1123(s.now()))
+	}
+
+	signature, err := s.signer.Sign(req.Context(), []byte(s.StringToSign(req)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderAuthorization, "SharedKey "+s.accountName+":"+signature)
+
+	return nil
+}
+
+// StringToSign returns the string-to-sign for the request, which is useful
+// for debugging authorization failures.
+func (s *Signer) StringToSign(req *http.Request) string {
+	return strings.ToUpper(req.Method) + "\n" +
+		req.Header.Get("Content-Encoding") + "\n" +
+		req.Header.Get("Content-Language") + "\n" +
+		canonical.ContentLength(req) + "\n" +
+		req.Header.Get("Content-MD5") + "\n" +
+		req.Header.Get("Content-Type") + "\n" +
+		req.Header.Get(HeaderDate) + "\n" +
+		req.Header.Get("If-Modified-Since") + "\n" +
+		req.Header.Get("If-Match") + "\n" +
+		req.Header.Get("If-None-Match") + "\n" +
+		req.Header.Get("If-Unmodified-Since") + "\n" +
+		req.Header.Get("Range") + "\n" +
+		canonical.Headers(req.Header, canonicalizedHeaderPrefix) +
+		s.canonicalizedResource(req.URL)
+}
+
+// canonicalizedResource returns /{account}/{path}, followed by each query
+// parameter on its own line, with names lowercased and sorted and values
+// comma separated.
+func (s *Signer) canonicalizedResource(u *url.URL) string {
+	return "/" + s.accountName + u.EscapedPath() + canonical.Query(u.Query())
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package batch
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "c2Fzc3ktYmF0Y2gtYWNjb3VudC1rZXktMDEyMzQ1Njc4OWFiY2RlZg=="
+
+// TestSigner_StringToSign_Documented checks the string-to-sign against the
+// example published with the Batch service's Shared Key documentation.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/batchservice/authenticate-requests-to-the-azure-batch-service
+func TestSigner_StringToSign_Documented(t *testing.T) {
+	const (
+		wantStringToSign = "GET\n\n\n\n\napplication/json;odata=minimalmetadata\n\n\n\n\n\n\n" +
+			"ocp-date:Tue, 29 Jul 2014 21:49:13 GMT\n" +
+			"/myaccount/jobs\napi-version:2014-04-01.1.0\ntimeout:20"
+		// wantAuthorization is the HMAC-SHA256 of the documented
+		// string-to-sign under testKey, computed independently.
+		wantAuthorization = "SharedKey myaccount:olTjQWgpPc/IQlT9MUwLvEvIlVELmCCQKRzWnYMAkXI="
+	)
+
+	signer, err := NewSignerFromKey("myaccount", testKey)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey() unexpected error: %v", err)
+	}
+	defer signer.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://myaccount.westus.batch.azure.com/jobs?api-version=2014-04-01.1.0&timeout=20", nil)
+	req.Header.Set("Content-Type", "application/json;odata=minimalmetadata")
+	req.Header.Set(HeaderOCPDate, "Tue, 29 Jul 2014 21:49:13 GMT")
+
+	if err = signer.SignRequest(req); err != nil {
+		t.Fatalf("SignRequest() unexpected error: %v", err)
+	}
+
+	if got := signer.StringToSign(req); got != wantStringToSign {
+		t.Errorf("StringToSign()\ngot:  = %q\nwant: %q\n", got, wantStringToSign)
+	}
+	if got := req.Header.Get(HeaderAuthorization); got != wantAuthorization {
+		t.Errorf("SignRequest() authorization\ngot:  = %v\nwant: %v\n", got, wantAuthorization)
+	}
+}
+
+func TestSigner_SignRequest(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name              string
+		method            string
+		url               string
+		body              string
+		headers           map[string]string
+		wantStringToSign  string
+		wantAuthorization string
+	}{
+		{
+			name:   "Should sign a request, lowercasing query parameter names",
+			method: http.MethodGet,
+			url:    "https://sassy.westus2.batch.azure.com/jobs?timeout=20&Api-Version=2021-06-01.14.0",
+			wantStringToSign: "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+				"ocp-date:Sun, 12 Dec 2021 10:10:10 GMT\n" +
+				"/sassy/jobs\napi-version:2021-06-01.14.0\ntimeout:20",
+			wantAuthorization: "SharedKey sassy:wmgRCcSdOR+ilYo7X2jUTMuRAxYG/wnuKZW6CK1Baz4=",
+		},
+		{
+			name:   "Should sign a request with content",
+			method: http.MethodPost,
+			url:    "https://sassy.westus2.batch.azure.com/pools?api-version=2021-06-01.14.0",
+			body:   `{"id":"hpc-pool","vmSize":"standard_d2s_v3"}`,
+			headers: map[string]string{
+				"Content-Type": "application/json; odata=minimalmetadata",
+			},
+			wantStringToSign: "POST\n\n\n44\n\napplication/json; odata=minimalmetadata\n\n\n\n\n\n\n" +
+				"ocp-date:Sun, 12 Dec 2021 10:10:10 GMT\n" +
+				"/sassy/pools\napi-version:2021-06-01.14.0",
+			wantAuthorization: "SharedKey sassy:AvE1IHVXIk7niYDjLDqU3fjMpwMEDzSRHHZNsv9jDgo=",
+		},
+		{
+			name:   "Should sign a conditional request with an escaped path",
+			method: http.MethodPatch,
+			url:    "https://sassy.westus2.batch.azure.com/jobs/job%201?api-version=2021-06-01.14.0",
+			headers: map[string]string{
+				"Content-Length": "0",
+				"If-Match":       `"0x8D9BD3F1C6DD0A0"`,
+			},
+			wantStringToSign: "PATCH\n\n\n\n\n\n\n\n\"0x8D9BD3F1C6DD0A0\"\n\n\n\n" +
+				"ocp-date:Sun, 12 Dec 2021 10:10:10 GMT\n" +
+				"/sassy/jobs/job%201\napi-version:2021-06-01.14.0",
+			wantAuthorization: "SharedKey sassy:0cHoiHK/FxexZQK73JPXFjLdEkS7Cxb7ITEIJ5ypHYw=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSignerFromKey("sassy", testKey)
+			if err != nil {
+				t.Fatalf("NewSignerFromKey() unexpected error: %v", err)
+			}
+			defer signer.Close()
+			signer.now = now
+
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body == "" {
+				req, _ = http.NewRequest(tt.method, tt.url, nil)
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if err = signer.SignRequest(req); err != nil {
+				t.Fatalf("SignRequest() unexpected error: %v", err)
+			}
+
+			if got := signer.StringToSign(req); got != tt.wantStringToSign {
+				t.Errorf("StringToSign()\ngot:  = %q\nwant: %q\n", got, tt.wantStringToSign)
+			}
+			if got := req.Header.Get(HeaderAuthorization); got != tt.wantAuthorization {
+				t.Errorf("SignRequest() authorization\ngot:  = %v\nwant: %v\n", got, tt.wantAuthorization)
+			}
+		})
+	}
+}
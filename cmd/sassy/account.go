@@ -0,0 +1,239 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	// Standard Library Imports
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage"
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/endpoints"
+	"github.com/matthewhartstonge/sassy/storage/versions"
+)
+
+// Account SAS output formats.
+const (
+	outputToken            = "token"
+	outputURL              = "url"
+	outputConnectionString = "connection-string"
+)
+
+// accountExitCodes maps each error account SAS generation can fail with to a
+// distinct exit code, so scripts can tell failures apart without parsing
+// stderr. Errors not listed exit with exitError.
+var accountExitCodes = []struct {
+	err  error
+	code int
+}{
+	{err: storage.ErrDecodingStorageAccountKey, code: 10},
+	{err: storage.ErrSignerRequired, code: 11},
+	{err: storage.ErrInvalidVersion, code: 12},
+	{err: storage.ErrInvalidStartDateFormat, code: 13},
+	{err: storage.ErrInvalidExpiryDateFormat, code: 14},
+	{err: storage.ErrInvalidIPv4Format, code: 15},
+	{err: storage.ErrIPv6NotSupported, code: 16},
+	{err: storage.ErrInvalidClockSkew, code: 17},
+	{err: storage.ErrStartAfterExpiry, code: 18},
+	{err: storage.ErrExpiryInPast, code: 19},
+	{err: storage.ErrInvalidTimeZone, code: 20},
+	{err: storage.ErrInvalidEndpointsProtocol, code: 21},
+	{err: storage.ErrInvalidEndpoint, code: 22},
+	{err: storage.ErrInvalidSharedAccessSignature, code: 23},
+	{err: storage.ErrAccountKeyAndSAS, code: 24},
+	{err: storage.ErrDevelopmentStorageOnly, code: 25},
+	{err: storage.ErrDevelopmentStorageExclusive, code: 26},
+	{err: storage.ErrEndpointsRequired, code: 27},
+	{err: storage.ErrNoSignedServices, code: 28},
+	{err: aztime.ErrDateTimeEmpty, code: 29},
+	{err: aztime.ErrDateTimeNonExistent, code: 30},
+	{err: aztime.ErrDateTimeAmbiguous, code: 31},
+}
+
+// accountExitCode returns the exit code for an account SAS error.
+func accountExitCode(err error) int {
+	for _, c := range accountExitCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return exitError
+}
+
+// runAccount generates an account SAS, printing it as a token, a signed URL
+// or a SAS connection string. Flags map one-to-one onto storage.NewAccountSAS
+// and its options.
+func runAccount(args []string, std stdio) int {
+	fs := newFlagSet("account", std)
+	account := fs.String("account", "", "storage account name")
+	key := fs.String("key", "", "base64 encoded storage account key")
+	keyFile := fs.String("key-file", "", "file containing the base64 encoded storage account key")
+	version := fs.String("version", versions.Latest.String(), "signed storage service version")
+	signedServices := fs.String("services", "", "signed services, any of b (blob), q (queue), t (table) and f (file)")
+	resourceTypes := fs.String("resource-types", "", "signed resource types, any of s (service), c (container) and o (object)")
+	permissions := fs.String("permissions", "", "signed permissions, for example, rl")
+	start := fs.String("start", "", "signed start as an ISO 8601 date time, valid immediately if not provided")
+	expiry := fs.String("expiry", "", "signed expiry as an ISO 8601 date time")
+	ip := fs.String("ip", "", "IPv4 address, dash separated IPv4 address range or IPv4 CIDR requests must come from")
+	signedProtocols := fs.String("protocols", "", "signed protocols, https or https,http")
+	apiVersion := fs.String("api-version", "", "api-version query parameter to include")
+	tz := fs.String("tz", "", "IANA timezone zone-less start and expiry are interpreted in, defaults to the local timezone")
+	clockSkew := fs.Duration("clock-skew", 0, "backdate the signed start by this duration if --start isn't provided, for example, 15m")
+	output := fs.String("output", outputToken, "output format, one of token, url or connection-string")
+	resourceURL := fs.String("url", "", "resource URL to sign with --output url, defaults to the first signed service's endpoint")
+	fs.Usage = func() { accountUsage(fs.Output(), fs.PrintDefaults) }
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	switch {
+	case *account == "":
+		return fail(std, exitUsage, errors.New("--account must be provided"))
+
+	case *expiry == "":
+		return fail(std, exitUsage, errors.New("--expiry must be provided"))
+
+	case *output != outputToken && *output != outputURL && *output != outputConnectionString:
+		return fail(std, exitUsage, fmt.Errorf("unknown --output %q, must be one of token, url or connection-string", *output))
+
+	case *resourceURL != "" && *output != outputURL:
+		return fail(std, exitUsage, errors.New("--url can only be provided with --output url"))
+	}
+
+	accountKey, err := readSecret("key", *key, *keyFile)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+
+	var opts []storage.AccountSASOption
+	if *start != "" {
+		opts = append(opts, storage.WithSignedStart(*start))
+	}
+	if *ip != "" {
+		opts = append(opts, storage.WithSignedIP(*ip))
+	}
+	if *signedProtocols != "" {
+		opts = append(opts, storage.WithSignedProtocols(*signedProtocols))
+	}
+	if *apiVersion != "" {
+		opts = append(opts, storage.WithAPIVersion(*apiVersion))
+	}
+	if *tz != "" {
+		opts = append(opts, storage.WithTimeZone(*tz))
+	}
+	if *clockSkew != 0 {
+		opts = append(opts, storage.WithClockSkew(*clockSkew))
+	}
+
+	sas, err := storage.NewAccountSAS(
+		*account,
+		accountKey,
+		*version,
+		*signedServices,
+		*resourceTypes,
+		*permissions,
+		*expiry,
+		opts...,
+	)
+	if err != nil {
+		return fail(std, accountExitCode(err), err)
+	}
+	defer sas.Close()
+
+	for _, warning := range sas.Warnings() {
+		fmt.Fprintf(std.err, "sassy: warning: %v\n", warning)
+	}
+
+	var out string
+	switch *output {
+	case outputURL:
+		u, err := accountURL(sas, *resourceURL)
+		if err != nil {
+			return fail(std, accountExitCode(err), err)
+		}
+
+		signed, err := sas.SignURL(u)
+		if err != nil {
+			return fail(std, accountExitCode(err), err)
+		}
+		out = signed.String()
+
+	case outputConnectionString:
+		out, err = sas.ConnectionString()
+
+	default:
+		out, err = sas.Token()
+	}
+	if err != nil {
+		return fail(std, accountExitCode(err), err)
+	}
+
+	fmt.Fprintln(std.out, out)
+
+	return exitOK
+}
+
+// accountURL returns the URL to sign, being the provided resource URL, or the
+// endpoint of the first signed service.
+func accountURL(sas *storage.AccountSAS, resourceURL string) (*url.URL, error) {
+	if resourceURL != "" {
+		u, err := url.Parse(resourceURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, storage.ErrInvalidEndpoint
+		}
+
+		return u, nil
+	}
+
+	if len(sas.SignedServices) == 0 {
+		return nil, storage.ErrNoSignedServices
+	}
+
+	ep, err := sas.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := endpoints.FromSignedService(sas.SignedServices[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return ep.URL(service), nil
+}
+
+func accountUsage(w io.Writer, printDefaults func()) {
+	fmt.Fprintln(w, "Usage: sassy account --account NAME --key-file FILE --expiry DATETIME [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Generates a storage account SAS.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags:")
+	printDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Exit codes:")
+	fmt.Fprintf(w, "  %-3d %s\n", exitOK, "success")
+	fmt.Fprintf(w, "  %-3d %s\n", exitError, "unexpected error")
+	fmt.Fprintf(w, "  %-3d %s\n", exitUsage, "invalid flags")
+	for _, c := range accountExitCodes {
+		fmt.Fprintf(w, "  %-3d %v\n", c.code, c.err)
+	}
+}
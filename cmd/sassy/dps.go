@@ -0,0 +1,116 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	// Standard Library Imports
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/iothub/dps"
+)
+
+func runDPS(args []string, std stdio) int {
+	if len(args) == 0 || args[0] != "derive" {
+		fmt.Fprintln(std.err, "Usage: sassy dps derive [flags]")
+		fmt.Fprintln(std.err)
+		fmt.Fprintln(std.err, "Commands:")
+		fmt.Fprintln(std.err, "  derive     derive group enrollment device keys from registration IDs")
+		return exitUsage
+	}
+
+	return runDPSDerive(args[1:], std)
+}
+
+// runDPSDerive derives a device key for each registration ID read, one per
+// line, writing "registrationId,deviceKey" CSV records. Blank lines and lines
+// starting with '#' are skipped. Every registration ID is validated before any
+// keys are written, so a bad input never produces a partial batch.
+func runDPSDerive(args []string, std stdio) int {
+	fs := newFlagSet("dps derive", std)
+	groupKey := fs.String("group-key", "", "base64 encoded enrollment group key")
+	groupKeyFile := fs.String("group-key-file", "", "file containing the base64 encoded enrollment group key")
+	input := fs.String("input", "-", "file of registration IDs, one per line, or - for stdin")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	key, err := readSecret("group-key", *groupKey, *groupKeyFile)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+
+	signer, err := dps.NewGroupKeySigner(key)
+	if err != nil {
+		return fail(std, exitError, err)
+	}
+	if closer, ok := signer.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	in := std.in
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return fail(std, exitError, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var registrationIDs []string
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		registrationID := strings.TrimSpace(scanner.Text())
+		if registrationID == "" || strings.HasPrefix(registrationID, "#") {
+			continue
+		}
+
+		if err = dps.ValidateRegistrationID(registrationID); err != nil {
+			return fail(std, exitError, fmt.Errorf("line %d: %w", line, err))
+		}
+		registrationIDs = append(registrationIDs, registrationID)
+	}
+	if err = scanner.Err(); err != nil {
+		return fail(std, exitError, err)
+	}
+
+	w := csv.NewWriter(std.out)
+	for _, registrationID := range registrationIDs {
+		deviceKey, err := dps.DeriveKey(context.Background(), signer, registrationID)
+		if err != nil {
+			return fail(std, exitError, fmt.Errorf("%s: %w", registrationID, err))
+		}
+
+		if err = w.Write([]string{registrationID, deviceKey}); err != nil {
+			return fail(std, exitError, err)
+		}
+	}
+
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return fail(std, exitError, err)
+	}
+
+	return exitOK
+}
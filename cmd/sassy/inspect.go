@@ -0,0 +1,508 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	// Standard Library Imports
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/ips"
+	"github.com/matthewhartstonge/sassy/storage/permissions"
+	"github.com/matthewhartstonge/sassy/storage/protocols"
+)
+
+// Inspection output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// longLived is how long a token can be valid for before it is reported as
+// long-lived.
+const longLived = 30 * 24 * time.Hour
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// storageParams describes each storage SAS parameter, in the order they are
+// listed.
+var storageParams = []struct {
+	name        string
+	description string
+}{
+	{name: "api-version", description: "API version"},
+	{name: "sv", description: "signed version"},
+	{name: "ss", description: "signed services"},
+	{name: "srt", description: "signed resource types"},
+	{name: "sr", description: "signed resource"},
+	{name: "sp", description: "signed permissions"},
+	{name: "st", description: "signed start"},
+	{name: "se", description: "signed expiry"},
+	{name: "sip", description: "signed IP"},
+	{name: "spr", description: "signed protocols"},
+	{name: "si", description: "stored access policy"},
+	{name: "sdd", description: "signed directory depth"},
+	{name: "tn", description: "table name"},
+	{name: "spk", description: "start partition key"},
+	{name: "srk", description: "start row key"},
+	{name: "epk", description: "end partition key"},
+	{name: "erk", description: "end row key"},
+	{name: "skoid", description: "signing key object ID"},
+	{name: "sktid", description: "signing key tenant ID"},
+	{name: "skt", description: "signing key start"},
+	{name: "ske", description: "signing key expiry"},
+	{name: "sks", description: "signing key service"},
+	{name: "skv", description: "signing key version"},
+	{name: "saoid", description: "authorized object ID"},
+	{name: "suoid", description: "unauthorized object ID"},
+	{name: "scid", description: "correlation ID"},
+	{name: "ses", description: "encryption scope"},
+	{name: "rscc", description: "Cache-Control response header"},
+	{name: "rscd", description: "Content-Disposition response header"},
+	{name: "rsce", description: "Content-Encoding response header"},
+	{name: "rscl", description: "Content-Language response header"},
+	{name: "rsct", description: "Content-Type response header"},
+	{name: "sig", description: "signature"},
+}
+
+// storageValueNames names the single letter values of the signed services,
+// signed resource types and signed resource parameters.
+var storageValueNames = map[string]map[string]string{
+	"ss":  {"b": "Blob", "q": "Queue", "t": "Table", "f": "File"},
+	"srt": {"s": "Service", "c": "Container", "o": "Object"},
+	"sr": {
+		"b":  "Blob",
+		"bs": "Blob snapshot",
+		"bv": "Blob version",
+		"c":  "Container",
+		"d":  "Directory",
+		"f":  "File",
+		"s":  "Share",
+	},
+}
+
+// inspection describes a decoded shared access signature.
+type inspection struct {
+	Kind        string       `json:"kind"`
+	Resource    string       `json:"resource,omitempty"`
+	Status      string       `json:"status"`
+	Start       *timestamp   `json:"start,omitempty"`
+	Expiry      *timestamp   `json:"expiry,omitempty"`
+	Permissions []permission `json:"permissions,omitempty"`
+	IP          string       `json:"ip,omitempty"`
+	Protocols   string       `json:"protocols,omitempty"`
+	Parameters  []parameter  `json:"parameters"`
+	Warnings    []string     `json:"warnings,omitempty"`
+}
+
+// timestamp is a time in both UTC and the display timezone.
+type timestamp struct {
+	UTC   time.Time `json:"utc"`
+	Local time.Time `json:"local"`
+}
+
+// permission is a granted permission and its name.
+type permission struct {
+	Letter string `json:"letter"`
+	Name   string `json:"name"`
+}
+
+// parameter is a decoded SAS parameter.
+type parameter struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+// runInspect decodes a shared access signature, printing every parameter,
+// the permissions granted, the validity window and any warnings.
+func runInspect(args []string, std stdio) int {
+	fs := newFlagSet("inspect", std)
+	output := fs.String("output", outputTable, "output format, one of table or json")
+	tz := fs.String("tz", "", "IANA timezone to display times in alongside UTC, defaults to the local timezone")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sassy inspect [flags] <token-or-url>")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Decodes a storage SAS token or URL, or a Service Bus or IoT Hub token. Use - to read from stdin.")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Flags:")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		return fail(std, exitUsage, fmt.Errorf("unknown --output %q, must be one of table or json", *output))
+	}
+
+	loc, err := displayLocation(*tz)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+
+	input, err := readToken(fs.Args(), std)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+
+	s, err := parseSAS(input)
+	if err != nil {
+		return fail(std, exitError, err)
+	}
+
+	in, err := inspect(s, now(), loc)
+	if err != nil {
+		return fail(std, exitError, err)
+	}
+
+	if *output == outputJSON {
+		enc := json.NewEncoder(std.out)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(in); err != nil {
+			return fail(std, exitError, err)
+		}
+
+		return exitOK
+	}
+
+	if err = in.writeTable(std.out); err != nil {
+		return fail(std, exitError, err)
+	}
+
+	return exitOK
+}
+
+// displayLocation returns the named timezone, or the local timezone if no
+// zone is provided.
+func displayLocation(zone string) (*time.Location, error) {
+	if zone == "" {
+		return time.Local, nil
+	}
+
+	loc, err := aztime.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("--tz: %w", err)
+	}
+
+	return loc, nil
+}
+
+// readToken returns the single token argument, reading it from stdin if it is
+// "-".
+func readToken(args []string, std stdio) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("a single token or URL must be provided")
+	}
+
+	if args[0] != "-" {
+		return args[0], nil
+	}
+
+	b, err := io.ReadAll(std.in)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// inspect describes the SAS as at the given time, displaying times in loc.
+func inspect(s *sas, at time.Time, loc *time.Location) (*inspection, error) {
+	start, expiry, err := s.times()
+	if err != nil {
+		return nil, err
+	}
+
+	in := &inspection{
+		Kind:     string(s.kind),
+		Resource: s.resource,
+		Status:   status(start, expiry, at),
+	}
+	if !start.IsZero() {
+		in.Start = &timestamp{UTC: start.UTC(), Local: start.In(loc)}
+	}
+	if !expiry.IsZero() {
+		in.Expiry = &timestamp{UTC: expiry.UTC(), Local: expiry.In(loc)}
+	}
+
+	if expiry.Sub(maxTime(start, at)) > longLived {
+		in.Warnings = append(in.Warnings, fmt.Sprintf("token is long-lived, remaining valid for more than %d days", longLived/(24*time.Hour)))
+	}
+
+	if s.isStorage() {
+		in.inspectStorage(s)
+	} else {
+		in.inspectBus(s)
+	}
+
+	return in, nil
+}
+
+// inspectStorage describes a storage SAS's parameters, permissions and
+// restrictions.
+func (in *inspection) inspectStorage(s *sas) {
+	known := map[string]bool{}
+	for _, p := range storageParams {
+		known[p.name] = true
+		if !s.query.Has(p.name) {
+			continue
+		}
+
+		value := s.query.Get(p.name)
+		in.Parameters = append(in.Parameters, parameter{
+			Name:        p.name,
+			Value:       value,
+			Description: describeValue(p.name, value, p.description),
+		})
+	}
+
+	// Query parameters of a URL which aren't part of the SAS are expected, so
+	// unknown parameters are only reported for bare tokens.
+	if !s.fromURL {
+		var unknown []string
+		for name := range s.query {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+
+		for _, name := range unknown {
+			in.Parameters = append(in.Parameters, parameter{Name: name, Value: s.query.Get(name), Description: "unknown"})
+			in.Warnings = append(in.Warnings, fmt.Sprintf("unknown parameter %q", name))
+		}
+	}
+
+	for _, letter := range strings.Split(s.query.Get("sp"), "") {
+		if letter == "" {
+			continue
+		}
+
+		name := permissions.SignedPermission(letter).NameFor(s.permissionKind())
+		if name == "" {
+			name = "unknown"
+			in.Warnings = append(in.Warnings, fmt.Sprintf("unknown permission %q", letter))
+		}
+
+		in.Permissions = append(in.Permissions, permission{Letter: letter, Name: name})
+	}
+
+	in.IP = s.query.Get("sip")
+	if in.IP == "" {
+		in.IP = "any"
+	} else if sip, err := ips.FromString(in.IP); err != nil {
+		in.Warnings = append(in.Warnings, fmt.Sprintf("signed IP %q is invalid: %v", in.IP, err))
+	} else {
+		for _, warning := range sip.Warnings() {
+			in.Warnings = append(in.Warnings, warning.Error())
+		}
+	}
+
+	in.Protocols = s.query.Get("spr")
+	if in.Protocols == "" {
+		in.Protocols = protocols.HTTPS.String() + "," + protocols.HTTP.String()
+	}
+	if strings.Contains(","+in.Protocols+",", ","+protocols.HTTP.String()+",") {
+		in.Warnings = append(in.Warnings, "token permits HTTP, so it can be read in transit")
+	}
+
+	if !s.query.Has("sig") {
+		in.Warnings = append(in.Warnings, "token is unsigned")
+	}
+
+	if si := s.query.Get("si"); si != "" {
+		in.Warnings = append(in.Warnings, fmt.Sprintf("permissions and validity may be set by stored access policy %q, which can be changed or revoked", si))
+	}
+}
+
+// inspectBus describes a Service Bus or IoT Hub token's parameters.
+func (in *inspection) inspectBus(s *sas) {
+	in.Parameters = []parameter{
+		{Name: "sr", Value: s.resource, Description: "resource URI"},
+		{Name: "sig", Value: s.signature, Description: "signature"},
+		{Name: "se", Value: aztime.ToUnix(s.expiry), Description: "expiry"},
+	}
+	if s.keyName != "" {
+		in.Parameters = append(in.Parameters, parameter{Name: "skn", Value: s.keyName, Description: "shared access policy"})
+	}
+}
+
+// describeValue appends the names of single letter values to the parameter's
+// description, for example, "signed services: Blob, File".
+func describeValue(name string, value string, description string) string {
+	names, ok := storageValueNames[name]
+	if !ok {
+		return description
+	}
+
+	if named, ok := names[value]; ok && name == "sr" {
+		return description + ": " + named
+	}
+
+	var out []string
+	for _, letter := range strings.Split(value, "") {
+		if named, ok := names[letter]; ok {
+			out = append(out, named)
+		} else {
+			out = append(out, "unknown "+letter)
+		}
+	}
+
+	return description + ": " + strings.Join(out, ", ")
+}
+
+// status describes whether the token is inside its validity window at the
+// given time.
+func status(start time.Time, expiry time.Time, at time.Time) string {
+	switch {
+	case expiry.IsZero():
+		return "no expiry, it may be set by a stored access policy"
+
+	case !at.Before(expiry):
+		return "expired " + humanDuration(at.Sub(expiry)) + " ago"
+
+	case !start.IsZero() && at.Before(start):
+		return "not yet valid, starts in " + humanDuration(start.Sub(at))
+
+	default:
+		return "valid, expires in " + humanDuration(expiry.Sub(at))
+	}
+}
+
+// humanDuration formats the duration to its two most significant units, for
+// example, "3d4h".
+func humanDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	seconds := (d % time.Minute) / time.Second
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+
+	case minutes > 0:
+		return fmt.Sprintf("%dm%ds", minutes, seconds)
+
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
+
+// writeTable writes the inspection as aligned text.
+func (in *inspection) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Kind:\t%s\n", in.Kind)
+	if in.Resource != "" {
+		fmt.Fprintf(tw, "Resource:\t%s\n", in.Resource)
+	}
+	fmt.Fprintf(tw, "Status:\t%s\n", in.Status)
+	if in.Start != nil {
+		fmt.Fprintf(tw, "Start:\t%s\n", in.Start)
+	}
+	if in.Expiry != nil {
+		fmt.Fprintf(tw, "Expiry:\t%s\n", in.Expiry)
+	}
+	if in.IP != "" {
+		fmt.Fprintf(tw, "IP:\t%s\n", in.IP)
+	}
+	if in.Protocols != "" {
+		fmt.Fprintf(tw, "Protocols:\t%s\n", in.Protocols)
+	}
+	if len(in.Permissions) > 0 {
+		var granted []string
+		for _, p := range in.Permissions {
+			granted = append(granted, p.Letter+" ("+p.Name+")")
+		}
+		fmt.Fprintf(tw, "Permissions:\t%s\n", strings.Join(granted, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PARAMETER\tVALUE\tDESCRIPTION")
+	for _, p := range in.Parameters {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Name, p.Value, p.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(in.Warnings) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Warnings:")
+		for _, warning := range in.Warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+// String implements Stringer, formatting the time in UTC and the display
+// timezone.
+func (t *timestamp) String() string {
+	return t.UTC.Format(time.RFC3339) + " (" + t.Local.Format("2006-01-02 15:04:05 MST") + ")"
+}
+
+// permissionKind returns the kind of SAS the permissions are granted by, as
+// some letters grant different operations per service. Blob and file SAS
+// always have a signed resource, while only table SAS name a table.
+func (s *sas) permissionKind() permissions.Kind {
+	if s.kind == kindAccount {
+		return permissions.KindAccount
+	}
+
+	switch s.query.Get("sr") {
+	case "":
+		if s.query.Has("tn") {
+			return permissions.KindTable
+		}
+
+		return permissions.KindQueue
+
+	case "f", "s":
+		return permissions.KindFile
+
+	default:
+		return permissions.KindBlob
+	}
+}
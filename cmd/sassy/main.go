@@ -0,0 +1,149 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command sassy generates Azure shared access signatures and keys from the
+// command line.
+package main
+
+import (
+	// Standard Library Imports
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// stdio holds the streams a command reads from and writes to, so commands can
+// be run against buffers in tests.
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// command is a sassy subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, std stdio) int
+}
+
+// commands returns the subcommands, in the order they are listed in usage.
+func commands() []command {
+	return []command{
+		{name: "account", summary: "generate storage account shared access signatures", run: runAccount},
+		{name: "inspect", summary: "decode a shared access signature token or URL", run: runInspect},
+		{name: "verify", summary: "check which key signed a shared access signature", run: runVerify},
+		{name: "dps", summary: "derive IoT Hub Device Provisioning Service device keys", run: runDPS},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], stdio{in: os.Stdin, out: os.Stdout, err: os.Stderr}))
+}
+
+// run dispatches to the subcommand named by the first argument, returning the
+// process exit code.
+func run(args []string, std stdio) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(std.err)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], std)
+		}
+	}
+
+	fmt.Fprintf(std.err, "sassy: unknown command %q\n\n", args[0])
+	usage(std.err)
+
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: sassy <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'sassy <command> -h' for help with a command.")
+}
+
+// newFlagSet returns a flag set which reports errors to stderr, rather than
+// exiting the process.
+func newFlagSet(name string, std stdio) *flag.FlagSet {
+	fs := flag.NewFlagSet("sassy "+name, flag.ContinueOnError)
+	fs.SetOutput(std.err)
+
+	return fs
+}
+
+// parseFlags parses the flags, returning the exit code to return with if the
+// command should not continue.
+func parseFlags(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+
+	return exitOK, true
+}
+
+// readSecret returns the secret provided as a flag value, or read from a file,
+// preferring the file so secrets needn't appear in the process list.
+func readSecret(name string, value string, file string) (string, error) {
+	switch {
+	case value != "" && file != "":
+		return "", fmt.Errorf("--%s and --%s-file must not both be provided", name, name)
+
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("reading --%s-file: %w", name, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+
+	case value != "":
+		return value, nil
+
+	default:
+		return "", fmt.Errorf("--%s or --%s-file must be provided", name, name)
+	}
+}
+
+// fail reports the error to stderr, returning the exit code.
+func fail(std stdio, code int, err error) int {
+	fmt.Fprintf(std.err, "sassy: %v\n", err)
+	return code
+}
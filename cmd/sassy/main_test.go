@@ -0,0 +1,446 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/sassy/storage"
+	"github.com/matthewhartstonge/sassy/storage/ips"
+)
+
+func TestRun_DPSDerive(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantCode int
+		wantOut  string
+	}{
+		{
+			name:  "Should derive a key per registration ID, skipping blanks and comments",
+			args:  []string{"dps", "derive", "--group-key", "c2Fzc3ktZ3JvdXAta2V5LTAxMjM0NTY3ODlhYmNkZWY="},
+			stdin: "# line one\nsensor-001\n\n  sensor-002  \n",
+			wantOut: "sensor-001,mr/xakbHHTfUIl6OCBM4tL6O/dJspgpa/Ny2FT+eOyU=\n" +
+				"sensor-002,uY3gwNdn5B6OJSxrAFObEEigQkQH0hUSrBAZQof4ItQ=\n",
+		},
+		{
+			name:     "Should not output a partial batch on an invalid registration ID",
+			args:     []string{"dps", "derive", "--group-key", "c2Fzc3ktZ3JvdXAta2V5LTAxMjM0NTY3ODlhYmNkZWY="},
+			stdin:    "sensor-001\nsensor/002\n",
+			wantCode: exitError,
+		},
+		{
+			name:     "Should error without a group key",
+			args:     []string{"dps", "derive"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "Should error on an unknown command",
+			args:     []string{"unknown"},
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, stdio{in: strings.NewReader(tt.stdin), out: &stdout, err: &stderr})
+			if code != tt.wantCode {
+				t.Fatalf("run()\ngot:  = %v\nwant: %v\nstderr: %s", code, tt.wantCode, stderr.String())
+			}
+
+			if got := stdout.String(); got != tt.wantOut {
+				t.Errorf("run()\ngot:  = %v\nwant: %v\n", got, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestRun_Account(t *testing.T) {
+	sasArgs := func(extra ...string) []string {
+		return append([]string{
+			"account",
+			"--account", "sassy",
+			"--key", "c2Fzc3ktc3RvcmFnZS1rZXktMDEyMzQ1Njc4OWFiY2RlZg==",
+			"--services", "bf",
+			"--resource-types", "sco",
+			"--permissions", "rl",
+		}, extra...)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+		wantErr  string
+	}{
+		{
+			name: "Should print a token",
+			args: sasArgs("--start", "2021-12-12T10:00:00Z", "--expiry", "2099-12-12T10:00:00Z", "--protocols", "https"),
+			wantOut: "se=2099-12-12T10%3A00%3A00Z&sig=dezVbc2ToB3rJt5FOwYmLgN5d%2Bg9%2F7bH6NTLV4yMJSE%3D" +
+				"&sp=rl&spr=https&srt=sco&ss=bf&st=2021-12-12T10%3A00%3A00Z&sv=2020-10-02\n",
+		},
+		{
+			name: "Should print a connection string, interpreting the start in the timezone",
+			args: sasArgs("--start", "2021-12-12T10:00:00", "--tz", "Pacific/Auckland", "--expiry", "2099-12-12T10:00:00Z", "--output", "connection-string"),
+			wantOut: "BlobEndpoint=https://sassy.blob.core.windows.net/;FileEndpoint=https://sassy.file.core.windows.net/;" +
+				"SharedAccessSignature=se=2099-12-12T10%3A00%3A00Z&sig=A6eDQVAG8HeLAqSm7edf480R3%2FXWMimX4jM5U1H%2FIyA%3D" +
+				"&sp=rl&srt=sco&ss=bf&st=2021-12-11T21%3A00%3A00Z&sv=2020-10-02\n",
+		},
+		{
+			name: "Should print a signed URL",
+			args: sasArgs("--start", "2021-12-12T10:00:00Z", "--expiry", "2099-12-12T10:00:00Z", "--protocols", "https", "--output", "url", "--url", "https://sassy.blob.core.windows.net/container?restype=container"),
+			wantOut: "https://sassy.blob.core.windows.net/container?restype=container&se=2099-12-12T10%3A00%3A00Z" +
+				"&sig=dezVbc2ToB3rJt5FOwYmLgN5d%2Bg9%2F7bH6NTLV4yMJSE%3D&sp=rl&spr=https&srt=sco&ss=bf&st=2021-12-12T10%3A00%3A00Z&sv=2020-10-02\n",
+		},
+		{
+			name: "Should warn about a signed IP covering private addresses",
+			args: sasArgs("--start", "2021-12-12T10:00:00Z", "--expiry", "2099-12-12T10:00:00Z", "--protocols", "https", "--ip", "10.0.0.0/8"),
+			wantOut: "se=2099-12-12T10%3A00%3A00Z&sig=GPg64SkEKM4Fz3XaC4Y13jSA8M%2BfbohhcP%2BvMq8EoMI%3D" +
+				"&sip=10.0.0.0-10.255.255.255&sp=rl&spr=https&srt=sco&ss=bf&st=2021-12-12T10%3A00%3A00Z&sv=2020-10-02\n",
+			wantErr: "sassy: warning: " + ips.ErrPrivateRange.Error() + "\n",
+		},
+		{
+			name:     "Should exit with the expiry in past code",
+			args:     sasArgs("--expiry", "2001-12-12T10:00:00Z"),
+			wantCode: 19,
+		},
+		{
+			name:     "Should exit with the invalid timezone code",
+			args:     sasArgs("--expiry", "2099-12-12T10:00:00Z", "--tz", "Middle/Earth"),
+			wantCode: 20,
+		},
+		{
+			name:     "Should exit with the non-existent date time code on a daylight saving gap",
+			args:     sasArgs("--start", "2099-09-27T02:30:00", "--tz", "Pacific/Auckland", "--expiry", "2099-12-12T10:00:00Z"),
+			wantCode: 30,
+		},
+		{
+			name:     "Should error on an unknown output format",
+			args:     sasArgs("--expiry", "2099-12-12T10:00:00Z", "--output", "yaml"),
+			wantCode: exitUsage,
+		},
+		{
+			name:     "Should error without an expiry",
+			args:     sasArgs(),
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, stdio{in: strings.NewReader(""), out: &stdout, err: &stderr})
+			if code != tt.wantCode {
+				t.Fatalf("run()\ngot:  = %v\nwant: %v\nstderr: %s", code, tt.wantCode, stderr.String())
+			}
+
+			if got := stdout.String(); got != tt.wantOut {
+				t.Errorf("run()\ngot:  = %v\nwant: %v\n", got, tt.wantOut)
+			}
+
+			if tt.wantErr != "" {
+				if got := stderr.String(); got != tt.wantErr {
+					t.Errorf("run() stderr\ngot:  = %v\nwant: %v\n", got, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_AccountVerify(t *testing.T) {
+	const storageKey = "c2Fzc3ktc3RvcmFnZS1rZXktMDEyMzQ1Njc4OWFiY2RlZg=="
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "Should verify a token generated without a signed start",
+			args: []string{"--expiry", "2099-12-12T10:00:00Z"},
+		},
+		{
+			name: "Should verify a token generated with a signed start",
+			args: []string{"--start", "2021-12-12T10:00:00Z", "--expiry", "2099-12-12T10:00:00Z", "--protocols", "https"},
+		},
+		{
+			name: "Should verify a token generated with a signed IP",
+			args: []string{"--expiry", "2099-12-12T10:00:00Z", "--ip", "1.1.1.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{
+				"account",
+				"--account", "sassy",
+				"--key", storageKey,
+				"--services", "bf",
+				"--resource-types", "sco",
+				"--permissions", "rl",
+			}, tt.args...)
+
+			var token, stderr bytes.Buffer
+			if code := run(args, stdio{in: strings.NewReader(""), out: &token, err: &stderr}); code != exitOK {
+				t.Fatalf("run() account\ngot:  = %v\nwant: %v\nstderr: %s", code, exitOK, stderr.String())
+			}
+
+			var stdout bytes.Buffer
+			args = []string{"verify", "--account", "sassy", "--key", storageKey, strings.TrimSpace(token.String())}
+			if code := run(args, stdio{in: strings.NewReader(""), out: &stdout, err: &stderr}); code != exitOK {
+				t.Fatalf("run() verify\ngot:  = %v\nwant: %v\nstdout: %s\nstderr: %s", code, exitOK, stdout.String(), stderr.String())
+			}
+
+			if want := "Signature:  valid, signed with --key #1\n"; !strings.Contains(stdout.String(), want) {
+				t.Errorf("run() verify\ngot:  = %v\nwant: %v\n", stdout.String(), want)
+			}
+		})
+	}
+}
+
+func TestRun_Inspect(t *testing.T) {
+	now = func() time.Time { return time.Date(2021, 12, 12, 11, 10, 10, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantCode     int
+		wantContains []string
+	}{
+		{
+			name: "Should inspect an account SAS URL",
+			args: []string{"inspect", "--tz", "Pacific/Auckland", "https://sassy.blob.core.windows.net/container?restype=container" +
+				"&se=2021-12-12T12%3A00%3A00Z&sig=dezVbc2ToB3rJt5FOwYmLgN5d%2Bg9%2F7bH6NTLV4yMJSE%3D&sp=rlp&spr=https" +
+				"&srt=sco&ss=bf&st=2021-12-12T10%3A00%3A00Z&sv=2020-10-02"},
+			wantContains: []string{
+				"Kind:         account SAS\n",
+				"Resource:     https://sassy.blob.core.windows.net/container\n",
+				"Status:       valid, expires in 49m50s\n",
+				"Expiry:       2021-12-12T12:00:00Z (2021-12-13 01:00:00 NZDT)\n",
+				"Permissions:  r (Read), l (List), p (Process)\n",
+				"ss         bf",
+				"signed services: Blob, File",
+			},
+		},
+		{
+			name:  "Should inspect a service SAS token from stdin, warning about HTTP and private IPs",
+			args:  []string{"inspect", "--tz", "UTC", "-"},
+			stdin: "?sv=2020-10-02&sr=b&sp=rw&se=2021-12-12T10%3A00%3A00Z&sip=10.0.0.0%2F8&sig=abc%3D\n",
+			wantContains: []string{
+				"Kind:         service SAS\n",
+				"Status:       expired 1h10m ago\n",
+				"Permissions:  r (Read), w (Write)\n",
+				"signed resource: Blob",
+				"token permits HTTP",
+				ips.ErrPrivateRange.Error(),
+			},
+		},
+		{
+			name: "Should name blob permissions",
+			args: []string{"inspect", "sv=2020-10-02&sr=c&sp=rlfi&se=2021-12-13T10%3A00%3A00Z&spr=https&sig=abc%3D"},
+			wantContains: []string{
+				"Permissions:  r (Read), l (List), f (Filter by tags), i (Set immutability policy)\n",
+			},
+		},
+		{
+			name: "Should name queue permissions",
+			args: []string{"inspect", "https://sassy.queue.core.windows.net/orders?sv=2020-10-02&sp=raup&se=2021-12-13T10%3A00%3A00Z&spr=https&sig=abc%3D"},
+			wantContains: []string{
+				"Permissions:  r (Read), a (Add), u (Update), p (Process)\n",
+			},
+		},
+		{
+			name: "Should name table permissions",
+			args: []string{"inspect", "sv=2020-10-02&tn=orders&sp=raud&se=2021-12-13T10%3A00%3A00Z&spr=https&sig=abc%3D"},
+			wantContains: []string{
+				"Permissions:  r (Read), a (Add), u (Update), d (Delete)\n",
+			},
+		},
+		{
+			name: "Should not name queue permissions for a file SAS",
+			args: []string{"inspect", "sv=2020-10-02&sr=f&sp=ru&se=2021-12-13T10%3A00%3A00Z&spr=https&sig=abc%3D"},
+			wantContains: []string{
+				"Permissions:  r (Read), u (unknown)\n",
+				`unknown permission "u"`,
+			},
+		},
+		{
+			name: "Should inspect a user delegation SAS, warning about unknown parameters",
+			args: []string{"inspect", "sv=2020-10-02&sr=c&sp=l&se=2021-12-13T10%3A00%3A00Z&spr=https&skoid=0000&sktid=1111&sig=abc%3D&foo=bar"},
+			wantContains: []string{
+				"Kind:         user delegation SAS\n",
+				"Status:       valid, expires in 22h49m\n",
+				`unknown parameter "foo"`,
+			},
+		},
+		{
+			name: "Should inspect a Service Bus token",
+			args: []string{"inspect", "--output", "json", "SharedAccessSignature sr=https%3A%2F%2Fsassy.servicebus.windows.net%2Forders&sig=abc%3D&se=1639303810&skn=send"},
+			wantContains: []string{
+				`"kind": "Service Bus SAS"`,
+				`"resource": "https://sassy.servicebus.windows.net/orders"`,
+				`"status": "expired 1h0m ago"`,
+			},
+		},
+		{
+			name: "Should inspect an IoT Hub token",
+			args: []string{"inspect", "SharedAccessSignature sr=sassy.azure-devices.net%2Fdevices%2Fsensor-001&sig=abc%3D&se=1639310400"},
+			wantContains: []string{
+				"Kind:      IoT Hub SAS\n",
+				"Status:    valid, expires in 49m50s\n",
+			},
+		},
+		{
+			name:     "Should error on an unrecognised token",
+			args:     []string{"inspect", "not-a-token"},
+			wantCode: exitError,
+		},
+		{
+			name:     "Should error without a token",
+			args:     []string{"inspect"},
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, stdio{in: strings.NewReader(tt.stdin), out: &stdout, err: &stderr})
+			if code != tt.wantCode {
+				t.Fatalf("run()\ngot:  = %v\nwant: %v\nstderr: %s", code, tt.wantCode, stderr.String())
+			}
+
+			for _, want := range tt.wantContains {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("run()\ngot:  = %v\nwant: %v\n", stdout.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Verify(t *testing.T) {
+	now = func() time.Time { return time.Date(2021, 12, 12, 11, 10, 10, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	dir := t.TempDir()
+	otherKeyFile := filepath.Join(dir, "key1")
+	storageKeyFile := filepath.Join(dir, "key2")
+	if err := os.WriteFile(otherKeyFile, []byte("c2Fzc3k=\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(storageKeyFile, []byte("c2Fzc3ktc3RvcmFnZS1rZXktMDEyMzQ1Njc4OWFiY2RlZg==\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	accountSAS := "se=2099-12-12T10%3A00%3A00Z&sig=dezVbc2ToB3rJt5FOwYmLgN5d%2Bg9%2F7bH6NTLV4yMJSE%3D" +
+		"&sp=rl&spr=https&srt=sco&ss=bf&st=2021-12-12T10%3A00%3A00Z&sv=2020-10-02"
+
+	sas, err := storage.NewAccountSAS("sassy", "c2Fzc3ktc3RvcmFnZS1rZXktMDEyMzQ1Njc4OWFiY2RlZg==", "2020-10-02", "bfqt", "sco", "rwdlacup", "2099-12-12T10:00:00Z", storage.WithSignedIP("1.1.1.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sas.Close()
+
+	libraryAccountSAS, err := sas.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		wantCode     int
+		wantContains []string
+	}{
+		{
+			name:         "Should report which key signed an account SAS",
+			args:         []string{"verify", "--account", "sassy", "--key-file", otherKeyFile, "--key-file", storageKeyFile, accountSAS},
+			wantContains: []string{"Signature:  valid, signed with " + storageKeyFile + "\n"},
+		},
+		{
+			name:         "Should verify an account SAS generated by the library",
+			args:         []string{"verify", "--account", "sassy", "--key-file", storageKeyFile, libraryAccountSAS},
+			wantContains: []string{"Signature:  valid, signed with " + storageKeyFile + "\n"},
+		},
+		{
+			name:     "Should dump the string-to-sign on a mismatch",
+			args:     []string{"verify", "--account", "other", "--key-file", storageKeyFile, accountSAS},
+			wantCode: exitSignatureMismatch,
+			wantContains: []string{
+				"Signature:  invalid, not signed by any of the keys\n",
+				"1  account name           \"other\"\n",
+				"8  signed protocols       \"https\"\n",
+			},
+		},
+		{
+			name: "Should report a genuine token outside of its validity window",
+			args: []string{"verify", "--account", "sassy", "--key-file", storageKeyFile,
+				"se=2021-12-12T10%3A00%3A00Z&sig=xxVgRaaWLw1o58ItlsTz7EJ2ZXLCsCt8pb%2BbBbXoaJ8%3D" +
+					"&sp=rl&spr=https&srt=sco&ss=bf&st=2021-12-12T09%3A00%3A00Z&sv=2020-10-02"},
+			wantCode: exitOutsideWindow,
+			wantContains: []string{
+				"Signature:  valid, signed with " + storageKeyFile + "\n",
+				"Status:     expired 1h10m ago\n",
+			},
+		},
+		{
+			name: "Should verify a blob service SAS URL",
+			args: []string{"verify", "--account", "sassy", "--key-file", storageKeyFile,
+				"https://sassy.blob.core.windows.net/reports/2021/summary.pdf?sp=r&st=2021-12-12T10:00:00Z" +
+					"&se=2021-12-13T10:00:00Z&spr=https&sv=2020-10-02&sr=b&sig=cuQCWjOMDSoo6HVPaE2YvszKc5ZT7z4tubjrj82cEmM%3D"},
+			wantContains: []string{"Kind:       service SAS\n", "Signature:  valid"},
+		},
+		{
+			name: "Should verify a Service Bus token with a raw key",
+			args: []string{"verify", "--key", "another-key", "--key", "sassy-servicebus-key",
+				"SharedAccessSignature sr=https%3a%2f%2fsassy.servicebus.windows.net%2forders" +
+					"&sig=Va0rLQ8MRI3%2FDwPtfq%2ByTir8XwhvUrsiyzC%2Fx40m%2Bfc%3D&se=1639310400&skn=send"},
+			wantContains: []string{"Signature:  valid, signed with --key #2\n"},
+		},
+		{
+			name:     "Should require the URL of a service SAS",
+			args:     []string{"verify", "--account", "sassy", "--key-file", storageKeyFile, "sp=r&se=2021-12-13T10:00:00Z&sv=2020-10-02&sr=b&sig=abc%3D"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "Should require the account of a storage SAS",
+			args:     []string{"verify", "--key-file", storageKeyFile, accountSAS},
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, stdio{in: strings.NewReader(""), out: &stdout, err: &stderr})
+			if code != tt.wantCode {
+				t.Fatalf("run()\ngot:  = %v\nwant: %v\nstderr: %s", code, tt.wantCode, stderr.String())
+			}
+
+			for _, want := range tt.wantContains {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("run()\ngot:  = %v\nwant: %v\n", stdout.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	// Standard Library Imports
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/iothub"
+	"github.com/matthewhartstonge/sassy/servicebus"
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+)
+
+// sasKind identifies the type of a shared access signature.
+type sasKind string
+
+const (
+	kindAccount        sasKind = "account SAS"
+	kindService        sasKind = "service SAS"
+	kindUserDelegation sasKind = "user delegation SAS"
+	kindServiceBus     sasKind = "Service Bus SAS"
+	kindIoTHub         sasKind = "IoT Hub SAS"
+)
+
+var (
+	errUnrecognisedSAS = errors.New("unrecognised shared access signature, expected a storage SAS token or URL, or a Service Bus or IoT Hub token")
+	errInvalidSAS      = errors.New("invalid shared access signature")
+)
+
+// sas is a parsed shared access signature.
+type sas struct {
+	kind sasKind
+	// resource is the URL a storage SAS was provided with, without its query,
+	// or the resource URI a Service Bus or IoT Hub token was signed for.
+	resource string
+	// fromURL reports whether the storage SAS was provided as a URL, in which
+	// case its query may hold parameters which aren't part of the SAS.
+	fromURL bool
+	// query holds a storage SAS's parameters.
+	query url.Values
+
+	// keyName, expiry, signature and stringToSign hold the fields of a
+	// Service Bus or IoT Hub token.
+	keyName      string
+	expiry       time.Time
+	signature    string
+	stringToSign string
+}
+
+// parseSAS parses a storage SAS token or URL, or a Service Bus or IoT Hub
+// token, detecting its kind.
+func parseSAS(input string) (*sas, error) {
+	input = strings.TrimSpace(input)
+	if strings.HasPrefix(input, servicebus.TokenPrefix) {
+		return parseBusSAS(input)
+	}
+
+	s := &sas{}
+	rawQuery := strings.TrimPrefix(input, "?")
+	if u, err := url.Parse(input); err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+		rawQuery = u.RawQuery
+		u.RawQuery = ""
+		u.Fragment = ""
+		s.resource = u.String()
+		s.fromURL = true
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errInvalidSAS
+	}
+
+	switch {
+	case query.Has("sv"):
+		s.query = query
+		// Base64 signatures pasted without encoding have their '+' decoded as
+		// a space, which can't otherwise appear in a signature.
+		if sig := query.Get("sig"); sig != "" {
+			s.query.Set("sig", strings.ReplaceAll(sig, " ", "+"))
+		}
+
+		switch {
+		case query.Has("skoid"):
+			s.kind = kindUserDelegation
+
+		case query.Has("ss") || query.Has("srt"):
+			s.kind = kindAccount
+
+		default:
+			s.kind = kindService
+		}
+
+		return s, nil
+
+	case !s.fromURL && query.Has("sr") && query.Has("se") && query.Has("sig"):
+		return parseBusSAS(rawQuery)
+
+	default:
+		return nil, errUnrecognisedSAS
+	}
+}
+
+// parseBusSAS parses a Service Bus or IoT Hub token, which share a format,
+// telling them apart by the resource URI. IoT Hub resource URIs don't include
+// a scheme.
+func parseBusSAS(token string) (*sas, error) {
+	t, err := servicebus.ParseToken(token)
+	if err != nil {
+		return nil, errInvalidSAS
+	}
+
+	if strings.Contains(t.Resource, "://") && !isIoTHost(t.Resource) {
+		return &sas{
+			kind:         kindServiceBus,
+			resource:     t.Resource,
+			keyName:      t.KeyName,
+			expiry:       t.Expiry,
+			signature:    t.Signature,
+			stringToSign: t.StringToSign(),
+		}, nil
+	}
+
+	it, err := iothub.ParseToken(token)
+	if err != nil {
+		return nil, errInvalidSAS
+	}
+
+	return &sas{
+		kind:         kindIoTHub,
+		resource:     it.Resource,
+		keyName:      it.KeyName,
+		expiry:       it.Expiry,
+		signature:    it.Signature,
+		stringToSign: it.StringToSign(),
+	}, nil
+}
+
+// isIoTHost reports whether the resource URI is served by IoT Hub or the
+// Device Provisioning Service.
+func isIoTHost(resource string) bool {
+	u, err := url.Parse(resource)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	return strings.HasSuffix(host, ".azure-devices.net") || strings.HasSuffix(host, ".azure-devices-provisioning.net")
+}
+
+// isStorage reports whether the SAS is an Azure Storage SAS.
+func (s *sas) isStorage() bool {
+	return s.query != nil
+}
+
+// times returns the SAS's signed start and expiry, either of which may be
+// zero if not present. Storage times which can't be parsed are returned as an
+// error naming the parameter.
+func (s *sas) times() (start time.Time, expiry time.Time, err error) {
+	if !s.isStorage() {
+		return time.Time{}, s.expiry, nil
+	}
+
+	if st := s.query.Get(aztime.ParamKeySignedStart); st != "" {
+		if start, err = aztime.ParseISO8601DateTimeInLocation(st, time.UTC); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid signed start " + st)
+		}
+	}
+
+	if se := s.query.Get(aztime.ParamKeySignedExpiry); se != "" {
+		if expiry, err = aztime.ParseISO8601DateTimeInLocation(se, time.UTC); err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid signed expiry " + se)
+		}
+	}
+
+	return start, expiry, nil
+}
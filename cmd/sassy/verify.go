@@ -0,0 +1,472 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+	"github.com/matthewhartstonge/sassy/storage/endpoints"
+)
+
+// Verification exit codes, so scripts can tell a forged token from a genuine
+// one used outside of its validity window.
+const (
+	exitSignatureMismatch = 3
+	exitOutsideWindow     = 4
+)
+
+// Storage service versions which changed the string-to-sign format.
+const (
+	version20181109 = "2018-11-09"
+	version20200210 = "2020-02-10"
+	version20201206 = "2020-12-06"
+)
+
+// developmentStorageServices maps the ports development storage serves each
+// service on to the service, as path-style URLs don't name the service.
+var developmentStorageServices = map[string]endpoints.Service{
+	"10000": endpoints.Blob,
+	"10001": endpoints.Queue,
+	"10002": endpoints.Table,
+}
+
+// stringList is a flag which can be provided more than once.
+type stringList []string
+
+// String implements flag.Value.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value.
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// field is a named line of a string-to-sign.
+type field struct {
+	name  string
+	value string
+}
+
+// stringToSign is the message a signature is computed over, kept as fields so
+// mismatches can be shown line by line.
+type stringToSign struct {
+	fields []field
+	// terminated is set if the final field is followed by a new line.
+	terminated bool
+}
+
+// String implements Stringer, returning the message to sign.
+func (s stringToSign) String() string {
+	values := make([]string, len(s.fields))
+	for i, f := range s.fields {
+		values[i] = f.value
+	}
+
+	message := strings.Join(values, "\n")
+	if s.terminated {
+		message += "\n"
+	}
+
+	return message
+}
+
+// runVerify recomputes a shared access signature's signature with each key,
+// reporting which key signed it and whether it is inside its validity window.
+func runVerify(args []string, std stdio) int {
+	var keys, keyFiles stringList
+	fs := newFlagSet("verify", std)
+	account := fs.String("account", "", "storage account name, required to verify storage SAS")
+	fs.Var(&keys, "key", "key to verify with, may be repeated")
+	fs.Var(&keyFiles, "key-file", "file containing a key to verify with, may be repeated")
+	tz := fs.String("tz", "", "IANA timezone to display times in alongside UTC, defaults to the local timezone")
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage: sassy verify [--account NAME] --key-file FILE [--key-file FILE] <token-or-url>")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Checks a SAS was signed by one of the keys. Storage and IoT Hub keys are base64")
+		fmt.Fprintln(w, "encoded, while Service Bus keys are used as is. User delegation SAS are verified")
+		fmt.Fprintln(w, "with the user delegation key's value. Service and user delegation SAS must be")
+		fmt.Fprintln(w, "provided as a URL, as the signature covers the resource. Use - to read from stdin.")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		fs.PrintDefaults()
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Exit codes:")
+		fmt.Fprintf(w, "  %-3d %s\n", exitOK, "signed by one of the keys and inside its validity window")
+		fmt.Fprintf(w, "  %-3d %s\n", exitError, "unexpected error")
+		fmt.Fprintf(w, "  %-3d %s\n", exitUsage, "invalid flags")
+		fmt.Fprintf(w, "  %-3d %s\n", exitSignatureMismatch, "not signed by any of the keys")
+		fmt.Fprintf(w, "  %-3d %s\n", exitOutsideWindow, "signed by one of the keys, but outside its validity window")
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if len(keys)+len(keyFiles) == 0 {
+		return fail(std, exitUsage, errors.New("--key or --key-file must be provided"))
+	}
+
+	loc, err := displayLocation(*tz)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+
+	input, err := readToken(fs.Args(), std)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+
+	s, err := parseSAS(input)
+	if err != nil {
+		return fail(std, exitError, err)
+	}
+
+	if s.isStorage() && *account == "" {
+		return fail(std, exitUsage, errors.New("--account must be provided to verify a storage SAS"))
+	}
+
+	message, err := s.buildStringToSign(*account)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+
+	keyring, err := s.keyring(keys, keyFiles)
+	if err != nil {
+		return fail(std, exitUsage, err)
+	}
+	defer keyring.Close()
+
+	signature := s.signature
+	if s.isStorage() {
+		signature = s.query.Get("sig")
+	}
+
+	keyName, ok, err := crypto.Verify(context.Background(), keyring, []byte(message.String()), signature)
+	if err != nil {
+		return fail(std, exitError, err)
+	}
+
+	in, err := inspect(s, now(), loc)
+	if err != nil {
+		return fail(std, exitError, err)
+	}
+
+	tw := tabwriter.NewWriter(std.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Kind:\t%s\n", in.Kind)
+	if ok {
+		fmt.Fprintf(tw, "Signature:\tvalid, signed with %s\n", keyName)
+	} else {
+		fmt.Fprintf(tw, "Signature:\tinvalid, not signed by any of the keys\n")
+	}
+	fmt.Fprintf(tw, "Status:\t%s\n", in.Status)
+	if in.Start != nil {
+		fmt.Fprintf(tw, "Start:\t%s\n", in.Start)
+	}
+	if in.Expiry != nil {
+		fmt.Fprintf(tw, "Expiry:\t%s\n", in.Expiry)
+	}
+	if err = tw.Flush(); err != nil {
+		return fail(std, exitError, err)
+	}
+
+	if !ok {
+		if err = message.writeDump(std.out); err != nil {
+			return fail(std, exitError, err)
+		}
+
+		return exitSignatureMismatch
+	}
+
+	if start, expiry, _ := s.times(); !withinWindow(start, expiry, now()) {
+		return exitOutsideWindow
+	}
+
+	return exitOK
+}
+
+// keyring returns a keyring holding each key, named by the file it was read
+// from, or by its position on the command line. Service Bus keys are used as
+// is, while every other kind of key is base64 decoded.
+func (s *sas) keyring(keys []string, keyFiles []string) (*crypto.Keyring, error) {
+	keyring := crypto.NewKeyring()
+	add := func(name string, value string) error {
+		var err error
+		if s.kind == kindServiceBus {
+			var key *crypto.Key
+			if key, err = crypto.NewKey([]byte(value)); err == nil {
+				err = keyring.Add(name, key)
+			}
+		} else {
+			err = keyring.AddBase64(name, value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		return nil
+	}
+
+	for i, value := range keys {
+		if err := add(fmt.Sprintf("--key #%d", i+1), value); err != nil {
+			keyring.Close()
+			return nil, err
+		}
+	}
+
+	for _, file := range keyFiles {
+		b, err := os.ReadFile(file)
+		if err == nil {
+			err = add(file, strings.TrimSpace(string(b)))
+		}
+		if err != nil {
+			keyring.Close()
+			return nil, err
+		}
+	}
+
+	return keyring, nil
+}
+
+// buildStringToSign returns the string-to-sign for the SAS's kind.
+func (s *sas) buildStringToSign(account string) (stringToSign, error) {
+	switch s.kind {
+	case kindAccount:
+		return s.accountStringToSign(account), nil
+
+	case kindService, kindUserDelegation:
+		return s.resourceStringToSign(account)
+
+	default:
+		resource, expiry, _ := strings.Cut(s.stringToSign, "\n")
+		return stringToSign{fields: []field{
+			{name: "resource URI", value: resource},
+			{name: "expiry", value: expiry},
+		}}, nil
+	}
+}
+
+// accountFieldNames names the fields of an account SAS's string-to-sign, in
+// order, for the mismatch dump.
+var accountFieldNames = []string{
+	"account name",
+	"signed permissions",
+	"signed services",
+	"signed resource types",
+	"signed start",
+	"signed expiry",
+	"signed IP",
+	"signed protocols",
+	"signed version",
+	"signed encryption scope",
+}
+
+// accountStringToSign returns an account SAS's string-to-sign, as signed by
+// the storage package, split into its fields.
+func (s *sas) accountStringToSign(account string) stringToSign {
+	message := storage.AccountStringToSign(account, s.query)
+	values := strings.Split(strings.TrimSuffix(message, "\n"), "\n")
+
+	fields := make([]field, len(values))
+	for i, value := range values {
+		fields[i].value = value
+		if i < len(accountFieldNames) {
+			fields[i].name = accountFieldNames[i]
+		}
+	}
+
+	return stringToSign{fields: fields, terminated: true}
+}
+
+// resourceStringToSign returns a service or user delegation SAS's
+// string-to-sign, which differs by service and signed version.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-service-sas#version-2018-11-09-and-later
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-user-delegation-sas#construct-a-user-delegation-signature
+func (s *sas) resourceStringToSign(account string) (stringToSign, error) {
+	if !s.fromURL {
+		return stringToSign{}, fmt.Errorf("a %s must be provided as a URL, as the signature covers the resource", s.kind)
+	}
+
+	u, err := url.Parse(s.resource)
+	if err != nil {
+		return stringToSign{}, err
+	}
+
+	service, pathStyle, err := storageService(u)
+	if err != nil {
+		return stringToSign{}, err
+	}
+
+	var opts []endpoints.Option
+	if pathStyle {
+		opts = append(opts, endpoints.WithDevelopmentStorage(u.Host))
+	}
+	ep, err := endpoints.New(account, opts...)
+	if err != nil {
+		return stringToSign{}, err
+	}
+
+	q := s.query
+	version := q.Get("sv")
+	canonicalizedResource := ep.SASCanonicalizedResource(service, u)
+	if service == endpoints.Table && q.Get("tn") != "" {
+		canonicalizedResource = "/" + endpoints.Table.String() + "/" + account + "/" + strings.ToLower(q.Get("tn"))
+	}
+
+	fields := []field{
+		{name: "signed permissions", value: q.Get("sp")},
+		{name: "signed start", value: q.Get("st")},
+		{name: "signed expiry", value: q.Get("se")},
+		{name: "canonicalized resource", value: canonicalizedResource},
+	}
+
+	if s.kind == kindUserDelegation {
+		fields = append(fields,
+			field{name: "signing key object ID", value: q.Get("skoid")},
+			field{name: "signing key tenant ID", value: q.Get("sktid")},
+			field{name: "signing key start", value: q.Get("skt")},
+			field{name: "signing key expiry", value: q.Get("ske")},
+			field{name: "signing key service", value: q.Get("sks")},
+			field{name: "signing key version", value: q.Get("skv")},
+		)
+		if version >= version20200210 {
+			fields = append(fields,
+				field{name: "authorized object ID", value: q.Get("saoid")},
+				field{name: "unauthorized object ID", value: q.Get("suoid")},
+				field{name: "correlation ID", value: q.Get("scid")},
+			)
+		}
+	} else {
+		fields = append(fields, field{name: "stored access policy", value: q.Get("si")})
+	}
+
+	fields = append(fields,
+		field{name: "signed IP", value: q.Get("sip")},
+		field{name: "signed protocols", value: q.Get("spr")},
+		field{name: "signed version", value: version},
+	)
+
+	switch service {
+	case endpoints.Queue:
+		return stringToSign{fields: fields}, nil
+
+	case endpoints.Table:
+		return stringToSign{fields: append(fields,
+			field{name: "start partition key", value: q.Get("spk")},
+			field{name: "start row key", value: q.Get("srk")},
+			field{name: "end partition key", value: q.Get("epk")},
+			field{name: "end row key", value: q.Get("erk")},
+		)}, nil
+
+	case endpoints.Blob:
+		if version >= version20181109 {
+			fields = append(fields,
+				field{name: "signed resource", value: q.Get("sr")},
+				field{name: "signed snapshot time", value: snapshotTime(q)},
+			)
+			if version >= version20201206 {
+				fields = append(fields, field{name: "signed encryption scope", value: q.Get("ses")})
+			}
+		}
+	}
+
+	return stringToSign{fields: append(fields,
+		field{name: "Cache-Control", value: q.Get("rscc")},
+		field{name: "Content-Disposition", value: q.Get("rscd")},
+		field{name: "Content-Encoding", value: q.Get("rsce")},
+		field{name: "Content-Language", value: q.Get("rscl")},
+		field{name: "Content-Type", value: q.Get("rsct")},
+	)}, nil
+}
+
+// withinWindow reports whether the time is at or after the start, if any, and
+// before the expiry, if any.
+func withinWindow(start time.Time, expiry time.Time, at time.Time) bool {
+	return (start.IsZero() || !at.Before(start)) && (expiry.IsZero() || at.Before(expiry))
+}
+
+// snapshotTime returns the snapshot or version a blob SAS is signed for.
+func snapshotTime(q url.Values) string {
+	switch q.Get("sr") {
+	case "bs":
+		return q.Get("snapshot")
+
+	case "bv":
+		return q.Get("versionid")
+
+	default:
+		return ""
+	}
+}
+
+// storageService returns the storage service a URL is served by, from its
+// subdomain, or its port for path-style development storage URLs. Data Lake
+// Storage Gen2 URLs are signed as blob URLs.
+func storageService(u *url.URL) (service endpoints.Service, pathStyle bool, err error) {
+	host := strings.ToLower(u.Hostname())
+	if net.ParseIP(host) != nil || host == "localhost" {
+		service, ok := developmentStorageServices[u.Port()]
+		if !ok {
+			return "", false, fmt.Errorf("unable to determine the storage service from development storage port %q", u.Port())
+		}
+
+		return service, true, nil
+	}
+
+	labels := strings.Split(host, ".")
+	for _, label := range labels[1:] {
+		switch endpoints.Service(label) {
+		case endpoints.Blob, endpoints.DFS:
+			return endpoints.Blob, false, nil
+
+		case endpoints.File, endpoints.Queue, endpoints.Table:
+			return endpoints.Service(label), false, nil
+		}
+	}
+
+	return "", false, fmt.Errorf("unable to determine the storage service from host %q", u.Hostname())
+}
+
+// writeDump writes each field of the string-to-sign, quoted so whitespace
+// differences are visible.
+func (s stringToSign) writeDump(w io.Writer) error {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "String-to-sign:")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, f := range s.fields {
+		fmt.Fprintf(tw, "  %d\t%s\t%q\n", i+1, f.name, f.value)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package connstr provides parsing of the `key=value;` formatted connection
+// strings used throughout Azure, for example, by Storage, Service Bus and IoT
+// Hub.
+package connstr
+
+import (
+	// Standard Library Imports
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	partSeparator     = ";"
+	keyValueSeparator = "="
+)
+
+var (
+	ErrEmpty        = errors.New("connection string is empty")
+	ErrMalformed    = errors.New("malformed, must be in the form key=value")
+	ErrDuplicateKey = errors.New("key specified more than once")
+	ErrMissingKey   = errors.New("required key is missing")
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// Error reports which part of a connection string is at fault. Values are
+// never included in the error, as they are likely to contain secrets.
+type Error struct {
+	// Key is the connection string key at fault. If the part is so malformed
+	// that it doesn't have a key, Key describes its position instead.
+	Key string
+	// Err describes the fault.
+	Err error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("connection string %s: %s", e.Key, e.Err)
+}
+
+// Unwrap enables errors.Is and errors.As to inspect the underlying fault.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Values holds parsed connection string values, keyed by key name.
+type Values map[string]string
+
+// Get returns the value for the given key, or an empty string if not set.
+func (v Values) Get(key string) string {
+	return v[key]
+}
+
+// Has reports whether the given key was set.
+func (v Values) Has(key string) bool {
+	_, ok := v[key]
+	return ok
+}
+
+// Parse splits a connection string into its key-value pairs. Keys are matched
+// case-insensitively against knownKeys and stored using the casing provided
+// in knownKeys. Other keys, such as the TransportType the Azure portal adds to
+// Service Bus connection strings, are kept as provided, so unknown keys don't
+// prevent a connection string from being used.
+//
+// Values are split on the first '=', so base64 encoded keys and SAS tokens are
+// preserved. Empty parts, such as from a trailing ';', are ignored.
+func Parse(connectionString string, knownKeys ...string) (Values, error) {
+	if strings.TrimSpace(connectionString) == "" {
+		return nil, ErrEmpty
+	}
+
+	canonical := make(map[string]string, len(knownKeys))
+	for _, key := range knownKeys {
+		canonical[strings.ToLower(key)] = key
+	}
+
+	values := Values{}
+	for i, part := range strings.Split(connectionString, partSeparator) {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(part, keyValueSeparator)
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			// Name the part by position, as the part may be a secret.
+			return nil, &Error{Key: fmt.Sprintf("part %d", i+1), Err: ErrMalformed}
+		}
+
+		if known, ok := canonical[strings.ToLower(key)]; ok {
+			key = known
+		}
+
+		if values.Has(key) {
+			return nil, &Error{Key: key, Err: ErrDuplicateKey}
+		}
+
+		values[key] = strings.TrimSpace(value)
+	}
+
+	if len(values) == 0 {
+		return nil, ErrEmpty
+	}
+
+	return values, nil
+}
+
+// Encode formats the given keys into a connection string, in the order
+// provided, skipping any keys that are unset.
+func (v Values) Encode(keys ...string) string {
+	var parts []string
+	for _, key := range keys {
+		if value, ok := v[key]; ok {
+			parts = append(parts, key+keyValueSeparator+value)
+		}
+	}
+
+	return strings.Join(parts, partSeparator)
+}
+
+// Require returns an error naming the first of the given keys that is missing
+// or empty.
+func (v Values) Require(keys ...string) error {
+	for _, key := range keys {
+		if v.Get(key) == "" {
+			return &Error{Key: key, Err: ErrMissingKey}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cosmos provides master key and resource token authorization of
+// Cosmos DB SQL API REST requests.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/cosmos-db/access-control-on-cosmosdb-resources
+package cosmos
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+	"github.com/matthewhartstonge/sassy/transport"
+)
+
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderMSDate        = "x-ms-date"
+	HeaderMSVersion     = "x-ms-version"
+
+	// DefaultVersion is the REST API version sent on requests that don't
+	// already specify one.
+	DefaultVersion = "2018-12-31"
+
+	tokenTypeMaster   = "master"
+	tokenTypeResource = "resource"
+	tokenVersion      = "1.0"
+)
+
+var (
+	ErrSignerRequired       = errors.New("a signer must be provided to sign requests")
+	ErrDecodingMasterKey    = errors.New("error decoding master key, must be base64 encoded")
+	ErrVersionEmpty         = errors.New("REST API version must not be empty")
+	ErrVerbEmpty            = errors.New("HTTP verb must not be empty")
+	ErrInvalidResourceToken = errors.New("invalid resource token, must be in the form type=resource&ver=1.0&sig=...")
+)
+
+// RequestSigner authorizes Cosmos DB REST requests, implemented by both
+// *Signer and *ResourceToken.
+type RequestSigner = transport.RequestSigner
+
+// Signer authorizes Cosmos DB REST requests with the account's master key.
+type Signer struct {
+	signer  crypto.Signer
+	version string
+	now     func() time.Time
+}
+
+// Option configures a Signer.
+type Option func(s *Signer) error
+
+// NewSigner returns a master key request signer, where signing is delegated
+// to the provided signer, for example, a crypto.Keyring.
+func NewSigner(signer crypto.Signer, opts ...Option) (*Signer, error) {
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	s := &Signer{
+		signer:  signer,
+		version: DefaultVersion,
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// NewSignerFromKey returns a master key request signer which signs with the
+// base64 encoded primary or secondary master key.
+func NewSignerFromKey(masterKey string, opts ...Option) (*Signer, error) {
+	key, err := crypto.DecodeKey(masterKey)
+	if err != nil {
+		return nil, ErrDecodingMasterKey
+	}
+
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrDecodingMasterKey
+	}
+
+	s, err := NewSigner(signer, opts...)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// WithVersion sets the x-ms-version header sent on requests that don't
+// already specify one. Defaults to DefaultVersion.
+func WithVersion(version string) Option {
+	return func(s *Signer) error {
+		if strings.TrimSpace(version) == "" {
+			return ErrVersionEmpty
+		}
+
+		s.version = version
+
+		return nil
+	}
+}
+
+// Close destroys the master key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// StringToSign returns the string-to-sign for a request. The verb, resource
+// type and date are lowercased, but the resource link keeps its case, as
+// resource IDs are case sensitive.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/cosmos-db/access-control-on-cosmosdb-resources#constructkeytoken
+func StringToSign(verb string, resourceType string, resourceLink string, date string) string {
+	return strings.ToLower(verb) + "\n" +
+		strings.ToLower(resourceType) + "\n" +
+		resourceLink + "\n" +
+		strings.ToLower(date) + "\n" +
+		"\n"
+}
+
+// Authorization returns the URL encoded master key Authorization header value
+// for a request, where date is the request's RFC 1123 formatted x-ms-date.
+func (s *Signer) Authorization(ctx context.Context, verb string, resourceType string, resourceLink string, date string) (string, error) {
+	if strings.TrimSpace(verb) == "" {
+		return "", ErrVerbEmpty
+	}
+
+	signature, err := s.signer.Sign(ctx, []byte(StringToSign(verb, resourceType, resourceLink, date)))
+	if err != nil {
+		return "", err
+	}
+
+	return authorization(tokenTypeMaster, signature), nil
+}
+
+// SignRequest authorizes the request in place, setting the x-ms-date and
+// x-ms-version headers if they have not been provided, then setting the
+// Authorization header. The resource type and link are derived from the
+// request's path.
+func (s *Signer) SignRequest(req *http.Request) error {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	setDefaultHeaders(req, s.version, s.now)
+
+	resourceType, resourceLink := ParseResource(req.URL.Path)
+	auth, err := s.Authorization(req.Context(), req.Method, resourceType, resourceLink, req.Header.Get(HeaderMSDate))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderAuthorization, auth)
+
+	return nil
+}
+
+// ResourceToken authorizes Cosmos DB REST requests with a resource token
+// issued for a permission, which grants access to specific resources without
+// handing out the master key.
+type ResourceToken struct {
+	token   string
+	version string
+	now     func() time.Time
+}
+
+// NewResourceToken returns a request signer for the resource token, as
+// returned in a permission's _token property. The token may be provided URL
+// encoded or as is.
+func NewResourceToken(token string) (*ResourceToken, error) {
+	token = strings.TrimSpace(token)
+	if strings.HasPrefix(strings.ToLower(token), "type%3d") {
+		// Only decode encoded tokens, as decoding a token as is would turn
+		// the '+' in its base64 signature into a space.
+		decoded, err := url.QueryUnescape(token)
+		if err != nil {
+			return nil, ErrInvalidResourceToken
+		}
+		token = decoded
+	}
+
+	if !strings.HasPrefix(token, "type="+tokenTypeResource+"&") || !strings.Contains(token, "&sig=") {
+		return nil, ErrInvalidResourceToken
+	}
+
+	return &ResourceToken{
+		token:   token,
+		version: DefaultVersion,
+		now:     time.Now,
+	}, nil
+}
+
+// SignRequest authorizes the request in place with the resource token,
+// setting the x-ms-date and x-ms-version headers if they have not been
+// provided.
+func (r *ResourceToken) SignRequest(req *http.Request) error {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	setDefaultHeaders(req, r.version, r.now)
+	req.Header.Set(HeaderAuthorization, url.QueryEscape(r.token))
+
+	return nil
+}
+
+// ParseResource derives the resource type and resource link the
+// string-to-sign requires from a request path. Paths ending in a resource ID,
+// such as /dbs/{db}/colls/{coll}, address that resource. Paths ending in a
+// resource type, such as /dbs/{db}/colls, address the parent's feed of that
+// type.
+func ParseResource(path string) (resourceType string, resourceLink string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		return "", ""
+	}
+
+	if len(segments)%2 == 1 {
+		return segments[len(segments)-1], strings.Join(segments[:len(segments)-1], "/")
+	}
+
+	return segments[len(segments)-2], strings.Join(segments, "/")
+}
+
+// authorization formats and URL encodes an Authorization header value.
+func authorization(tokenType string, signature string) string {
+	return url.QueryEscape("type=" + tokenType + "&ver=" + tokenVersion + "&sig=" + signature)
+}
+
+// setDefaultHeaders sets the x-ms-date and x-ms-version headers, if not set.
+func setDefaultHeaders(req *http.Request, version string, now func() time.Time) {
+	if req.Header.Get(HeaderMSDate) == "" {
+		req.Header.Set(HeaderMSDate, aztime.ToRFC1123(now()))
+	}
+
+	if req.Header.Get(HeaderMSVersion) == "" {
+		req.Header.Set(HeaderMSVersion, version)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cosmos
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testMasterKey = "c2Fzc3ktY29zbW9zLW1hc3Rlci1rZXktMDEyMzQ1Njc4OWFiY2RlZg=="
+
+func TestSigner_SignRequest(t *testing.T) {
+	tests := []struct {
+		name              string
+		method            string
+		url               string
+		wantAuthorization string
+	}{
+		{
+			name:              "Should sign a document read, keeping the resource link's case",
+			method:            http.MethodGet,
+			url:               "https://sassy.documents.azure.com/dbs/Sassy/colls/Orders/docs/order%201",
+			wantAuthorization: "type%3Dmaster%26ver%3D1.0%26sig%3DinF%2BuCsCufAwehBSMo6dJiz4nvg37dIqBtUsAYsGnQY%3D",
+		},
+		{
+			name:              "Should sign a document create against the parent collection",
+			method:            http.MethodPost,
+			url:               "https://sassy.documents.azure.com/dbs/Sassy/colls/Orders/docs",
+			wantAuthorization: "type%3Dmaster%26ver%3D1.0%26sig%3Dy7bVgJdiXoxDrP7TkWh0XmnuZWMeUItDb1tCzm1lVy8%3D",
+		},
+		{
+			name:              "Should sign a database list with an empty resource link",
+			method:            http.MethodGet,
+			url:               "https://sassy.documents.azure.com/dbs",
+			wantAuthorization: "type%3Dmaster%26ver%3D1.0%26sig%3D2tdODxXsSNkeP%2BYGbdkgYDkq3QK9q%2BETq9ibrNAOIi8%3D",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSignerFromKey(testMasterKey)
+			if err != nil {
+				t.Fatalf("NewSignerFromKey() error = %v", err)
+			}
+			defer s.Close()
+			s.now = func() time.Time { return time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC) }
+
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatalf("http.NewRequest() error = %v", err)
+			}
+
+			if err = s.SignRequest(req); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+
+			if got := req.Header.Get(HeaderMSDate); got != "Sun, 12 Dec 2021 10:10:10 GMT" {
+				t.Errorf("SignRequest() x-ms-date\ngot:  = %v\nwant: %v\n", got, "Sun, 12 Dec 2021 10:10:10 GMT")
+			}
+
+			if got := req.Header.Get(HeaderAuthorization); got != tt.wantAuthorization {
+				t.Errorf("SignRequest()\ngot:  = %v\nwant: %v\n", got, tt.wantAuthorization)
+			}
+		})
+	}
+}
+
+func TestParseResource(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		wantResourceType string
+		wantResourceLink string
+	}{
+		{
+			name: "Should return nothing for the account",
+			path: "/",
+		},
+		{
+			name:             "Should parse a database feed",
+			path:             "/dbs",
+			wantResourceType: "dbs",
+		},
+		{
+			name:             "Should parse a database",
+			path:             "/dbs/Sassy",
+			wantResourceType: "dbs",
+			wantResourceLink: "dbs/Sassy",
+		},
+		{
+			name:             "Should parse a stored procedure feed",
+			path:             "/dbs/Sassy/colls/Orders/sprocs/",
+			wantResourceType: "sprocs",
+			wantResourceLink: "dbs/Sassy/colls/Orders",
+		},
+		{
+			name:             "Should parse an attachment",
+			path:             "/dbs/Sassy/colls/Orders/docs/order-1/attachments/receipt",
+			wantResourceType: "attachments",
+			wantResourceLink: "dbs/Sassy/colls/Orders/docs/order-1/attachments/receipt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotLink := ParseResource(tt.path)
+			if gotType != tt.wantResourceType || gotLink != tt.wantResourceLink {
+				t.Errorf("ParseResource()\ngot:  = %v, %v\nwant: %v, %v\n", gotType, gotLink, tt.wantResourceType, tt.wantResourceLink)
+			}
+		})
+	}
+}
+
+func TestResourceToken_SignRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "Should encode a token provided as is",
+			token: "type=resource&ver=1&sig=ab+c/d==;e+f/g==;",
+			want:  "type%3Dresource%26ver%3D1%26sig%3Dab%2Bc%2Fd%3D%3D%3Be%2Bf%2Fg%3D%3D%3B",
+		},
+		{
+			name:  "Should not double encode an encoded token",
+			token: "type%3dresource%26ver%3d1%26sig%3dab%2bc%2fd%3d%3d%3be%2bf%2fg%3d%3d%3b",
+			want:  "type%3Dresource%26ver%3D1%26sig%3Dab%2Bc%2Fd%3D%3D%3Be%2Bf%2Fg%3D%3D%3B",
+		},
+		{
+			name:    "Should error on a master key token",
+			token:   "type=master&ver=1.0&sig=abc",
+			wantErr: ErrInvalidResourceToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewResourceToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewResourceToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, "https://sassy.documents.azure.com/dbs/Sassy/colls/Orders/docs/order-1", nil)
+			if err = r.SignRequest(req); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+
+			if got := req.Header.Get(HeaderAuthorization); got != tt.want {
+				t.Errorf("SignRequest()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
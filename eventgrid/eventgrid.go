@@ -0,0 +1,310 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package eventgrid provides generation, parsing and verification of the
+// shared access signature tokens Event Grid topics and domains accept in the
+// aeg-sas-token header.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/event-grid/authenticate-with-access-keys-shared-access-signature
+package eventgrid
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	// HeaderSASToken is the header a SAS token is sent in.
+	HeaderSASToken = "aeg-sas-token"
+	// HeaderSASKey is the header a topic key is sent in, when authenticating
+	// with the key itself rather than a SAS token.
+	HeaderSASKey = "aeg-sas-key"
+
+	paramResource  = "r"
+	paramExpiry    = "e"
+	paramSignature = "s"
+
+	// eventsPath is the path events are published to.
+	eventsPath = "/api/events"
+)
+
+var (
+	ErrSignerRequired    = errors.New("a signer must be provided to sign tokens")
+	ErrDecodingKey       = errors.New("error decoding topic key, must be base64 encoded")
+	ErrResourceEmpty     = errors.New("resource must not be empty")
+	ErrInvalidEndpoint   = errors.New("topic endpoint must be an absolute https URL")
+	ErrExpiryRequired    = errors.New("token expiry must be provided")
+	ErrInvalidToken      = errors.New("invalid Event Grid shared access signature token")
+	ErrSignatureMismatch = errors.New("token signature does not match")
+	ErrTokenExpired      = errors.New("token has expired")
+)
+
+// TopicResource returns the resource a topic or domain endpoint's tokens are
+// scoped to, appending the /api/events path if the endpoint doesn't include
+// it, for example, https://<topic>.<region>-1.eventgrid.azure.net/api/events.
+func TopicResource(endpoint string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", ErrInvalidEndpoint
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = eventsPath
+	}
+
+	return u.String(), nil
+}
+
+// Token is an Event Grid shared access signature token.
+type Token struct {
+	// Resource is the topic or domain endpoint the token grants access to.
+	Resource string
+	// Expiry is when the token stops being valid, at a resolution of seconds.
+	Expiry time.Time
+	// Signature is the base64 encoded HMAC-SHA256 signature.
+	Signature string
+
+	// encoded preserves the encoded resource and expiry parameters of a
+	// parsed token, as the signature is computed over the encoded form.
+	encoded string
+}
+
+// ParseToken parses an Event Grid shared access signature token, as sent in
+// the aeg-sas-token header.
+func ParseToken(token string) (*Token, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	// The signature is computed over everything before the signature
+	// parameter, which must come last.
+	unsigned, signature, ok := strings.Cut(token, "&"+paramSignature+"=")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	t := &Token{encoded: unsigned}
+	var err error
+	if t.Signature, err = url.QueryUnescape(signature); err != nil {
+		return nil, ErrInvalidToken
+	}
+	// Base64 never contains spaces, so an unencoded '+' must be restored.
+	t.Signature = strings.ReplaceAll(t.Signature, " ", "+")
+
+	params := strings.Split(unsigned, "&")
+	if len(params) != 2 {
+		return nil, ErrInvalidToken
+	}
+
+	for i, want := range []string{paramResource, paramExpiry} {
+		key, value, ok := strings.Cut(params[i], "=")
+		if !ok || key != want {
+			return nil, ErrInvalidToken
+		}
+
+		if value, err = url.QueryUnescape(value); err != nil {
+			return nil, ErrInvalidToken
+		}
+
+		switch key {
+		case paramResource:
+			t.Resource = value
+
+		case paramExpiry:
+			if t.Expiry, err = aztime.ParseEventGrid(value); err != nil {
+				return nil, ErrInvalidToken
+			}
+		}
+	}
+
+	if t.Resource == "" || t.Signature == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return t, nil
+}
+
+// String implements Stringer, returning the token as sent in the
+// aeg-sas-token header.
+func (t *Token) String() string {
+	return t.StringToSign() + "&" + paramSignature + "=" + urlEncode(t.Signature)
+}
+
+// StringToSign returns the message the token's signature is computed over.
+func (t *Token) StringToSign() string {
+	if t.encoded != "" {
+		return t.encoded
+	}
+
+	return paramResource + "=" + urlEncode(t.Resource) +
+		"&" + paramExpiry + "=" + urlEncode(aztime.ToEventGrid(t.Expiry))
+}
+
+// Expired reports whether the token has expired at the given time.
+func (t *Token) Expired(now time.Time) bool {
+	return !now.Before(t.Expiry)
+}
+
+// urlEncode encodes a token value the way .NET's HttpUtility.UrlEncode does,
+// which is how Event Grid expects tokens to be encoded: spaces as '+', hex
+// digits in lowercase, and '-', '_', '.', '!', '*', '(' and ')' unencoded.
+func urlEncode(value string) string {
+	const hex = "0123456789abcdef"
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			b.WriteByte(c)
+
+		case strings.IndexByte("-_.!*()", c) >= 0:
+			b.WriteByte(c)
+
+		case c == ' ':
+			b.WriteByte('+')
+
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0x0f])
+		}
+	}
+
+	return b.String()
+}
+
+// Signer generates and verifies Event Grid shared access signature tokens
+// with a topic or domain key.
+type Signer struct {
+	signer crypto.Signer
+	now    func() time.Time
+}
+
+// NewSigner returns a token signer, where signing is delegated to the
+// provided signer, for example, a crypto.Keyring holding both topic keys.
+func NewSigner(signer crypto.Signer) (*Signer, error) {
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	return &Signer{
+		signer: signer,
+		now:    time.Now,
+	}, nil
+}
+
+// NewSignerFromKey returns a token signer which signs with the base64
+// encoded topic or domain key.
+func NewSignerFromKey(key string) (*Signer, error) {
+	k, err := crypto.DecodeKey(key)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	signer, err := crypto.NewKeySigner(k)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	return NewSigner(signer)
+}
+
+// Close destroys the topic or domain key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// Token returns a token granting access to publish to the resource until
+// expiry.
+func (s *Signer) Token(resource string, expiry time.Time) (*Token, error) {
+	return s.TokenContext(context.Background(), resource, expiry)
+}
+
+// TokenContext returns a token granting access to publish to the resource
+// until expiry, passing the context through to the signer.
+func (s *Signer) TokenContext(ctx context.Context, resource string, expiry time.Time) (*Token, error) {
+	if strings.TrimSpace(resource) == "" {
+		return nil, ErrResourceEmpty
+	}
+
+	if expiry.IsZero() {
+		return nil, ErrExpiryRequired
+	}
+
+	t := &Token{
+		Resource: resource,
+		Expiry:   expiry.Truncate(time.Second).UTC(),
+	}
+
+	signature, err := crypto.SignWithExpiry(ctx, s.signer, []byte(t.StringToSign()), t.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	t.Signature = signature
+
+	return t, nil
+}
+
+// Verify reports whether the token was signed with the topic key and has not
+// expired, returning ErrSignatureMismatch or ErrTokenExpired if not. During
+// key rotation, a crypto.Keyring signer accepts tokens signed by either key.
+func (s *Signer) Verify(ctx context.Context, token *Token) error {
+	if token == nil {
+		return ErrInvalidToken
+	}
+
+	_, ok, err := crypto.Verify(ctx, s.signer, []byte(token.StringToSign()), token.Signature)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrSignatureMismatch
+	}
+
+	if token.Expired(s.now()) {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
+
+// NewTopicToken returns a token granting access to publish to the topic or
+// domain endpoint until expiry, signed with the base64 encoded topic key.
+func NewTopicToken(endpoint string, key string, expiry time.Time) (*Token, error) {
+	resource, err := TopicResource(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	return s.Token(resource, expiry)
+}
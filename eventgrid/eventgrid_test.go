@@ -0,0 +1,184 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventgrid
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	testKey      = "c2Fzc3ktdG9waWMta2V5LTAxMjM0NTY3ODlhYmNkZWY="
+	testEndpoint = "https://sassy.westus2-1.eventgrid.azure.net/api/events"
+)
+
+func TestNewTopicToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		expiry   time.Time
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "Should sign a morning expiry",
+			endpoint: testEndpoint,
+			expiry:   time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC),
+			want: "r=https%3a%2f%2fsassy.westus2-1.eventgrid.azure.net%2fapi%2fevents" +
+				"&e=12%2f12%2f2021+10%3a10%3a10+AM" +
+				"&s=AwgtGD8NrmiUgx%2fpf4yeAXrhSkermztQTMMmnR5%2bxso%3d",
+		},
+		{
+			name:     "Should sign an afternoon expiry without zero padding the hour",
+			endpoint: "https://sassy.westus2-1.eventgrid.azure.net",
+			expiry:   time.Date(2021, 12, 12, 15, 4, 5, 0, time.UTC),
+			want: "r=https%3a%2f%2fsassy.westus2-1.eventgrid.azure.net%2fapi%2fevents" +
+				"&e=12%2f12%2f2021+3%3a04%3a05+PM" +
+				"&s=RR40DAOVss4h9CTtc3Hv%2fnnMztTFnKPHOObNes0A6Ec%3d",
+		},
+		{
+			name:     "Should error on a http endpoint",
+			endpoint: "http://sassy.westus2-1.eventgrid.azure.net/api/events",
+			wantErr:  ErrInvalidEndpoint,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewTopicToken(tt.endpoint, testKey, tt.expiry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewTopicToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.String() != tt.want {
+				t.Errorf("NewTopicToken()\ngot:  = %v\nwant: %v\n", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    Token
+		wantErr error
+	}{
+		{
+			name: "Should parse a token",
+			token: "r=https%3a%2f%2fsassy.westus2-1.eventgrid.azure.net%2fapi%2fevents" +
+				"&e=12%2f12%2f2021+3%3a04%3a05+PM" +
+				"&s=RR40DAOVss4h9CTtc3Hv%2fnnMztTFnKPHOObNes0A6Ec%3d",
+			want: Token{
+				Resource:  testEndpoint,
+				Expiry:    time.Date(2021, 12, 12, 15, 4, 5, 0, time.UTC),
+				Signature: "RR40DAOVss4h9CTtc3Hv/nnMztTFnKPHOObNes0A6Ec=",
+			},
+		},
+		{
+			name:    "Should error if the signature isn't last",
+			token:   "r=a&s=b&e=12%2f12%2f2021+3%3a04%3a05+PM",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Should error on an ISO 8601 expiry",
+			token:   "r=a&e=2021-12-12T15%3a04%3a05Z&s=b",
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.Resource != tt.want.Resource || !got.Expiry.Equal(tt.want.Expiry) || got.Signature != tt.want.Signature {
+				t.Errorf("ParseToken()\ngot:  = %+v\nwant: %+v\n", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSigner_Verify(t *testing.T) {
+	token, err := ParseToken("r=https%3a%2f%2fsassy.westus2-1.eventgrid.azure.net%2fapi%2fevents" +
+		"&e=12%2f12%2f2021+3%3a04%3a05+PM" +
+		"&s=RR40DAOVss4h9CTtc3Hv%2fnnMztTFnKPHOObNes0A6Ec%3d")
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name: "Should verify a token",
+			keys: []string{testKey},
+			now:  time.Date(2021, 12, 12, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should verify a token signed by a retiring key",
+			keys: []string{"bmV3LXRvcGljLWtleQ==", testKey},
+			now:  time.Date(2021, 12, 12, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Should error on an expired token",
+			keys:    []string{testKey},
+			now:     time.Date(2021, 12, 12, 15, 4, 5, 0, time.UTC),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "Should error on a token signed by an unknown key",
+			keys:    []string{"bmV3LXRvcGljLWtleQ=="},
+			now:     time.Date(2021, 12, 12, 15, 0, 0, 0, time.UTC),
+			wantErr: ErrSignatureMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring := crypto.NewKeyring()
+			defer keyring.Close()
+			for i, key := range tt.keys {
+				if err := keyring.AddBase64(string(rune('a'+i)), key); err != nil {
+					t.Fatalf("AddBase64() error = %v", err)
+				}
+			}
+
+			s, err := NewSigner(keyring)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			s.now = func() time.Time { return tt.now }
+
+			if err = s.Verify(context.Background(), token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify()\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hmacauth
+
+import (
+	// Standard Library Imports
+	"errors"
+	"net/url"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+// Connection string keys. Azure Communication Services connection strings
+// use endpoint and accesskey, while Azure App Configuration connection strings
+// use Endpoint, Id and Secret. Keys are matched case-insensitively.
+const (
+	ConnectionStringEndpoint  = "Endpoint"
+	ConnectionStringAccessKey = "AccessKey"
+	ConnectionStringID        = "Id"
+	ConnectionStringSecret    = "Secret"
+)
+
+var (
+	ErrInvalidEndpoint    = errors.New("endpoint must be an absolute https URL")
+	ErrAccessKeyAndSecret = errors.New("an access key and a secret must not both be provided")
+)
+
+// ConnectionString holds a parsed Azure Communication Services or Azure App
+// Configuration connection string.
+type ConnectionString struct {
+	Endpoint *url.URL
+	// ID is the App Configuration access key ID. It is empty for
+	// Communication Services connection strings.
+	ID string
+	// Key is the Communication Services access key or App Configuration
+	// secret.
+	Key *crypto.Key
+}
+
+// ParseConnectionString parses an Azure Communication Services
+// (endpoint=...;accesskey=...) or Azure App Configuration
+// (Endpoint=...;Id=...;Secret=...) connection string.
+//
+// Errors are returned as a *connstr.Error, naming the malformed part of the
+// connection string.
+func ParseConnectionString(connectionString string) (*ConnectionString, error) {
+	values, err := connstr.Parse(
+		connectionString,
+		ConnectionStringEndpoint,
+		ConnectionStringAccessKey,
+		ConnectionStringID,
+		ConnectionStringSecret,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = values.Require(ConnectionStringEndpoint); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(values.Get(ConnectionStringEndpoint))
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, &connstr.Error{Key: ConnectionStringEndpoint, Err: ErrInvalidEndpoint}
+	}
+
+	cs := &ConnectionString{
+		Endpoint: endpoint,
+	}
+
+	keyName := ConnectionStringAccessKey
+	switch {
+	case values.Has(ConnectionStringAccessKey) && values.Has(ConnectionStringSecret):
+		return nil, &connstr.Error{Key: ConnectionStringSecret, Err: ErrAccessKeyAndSecret}
+
+	case values.Has(ConnectionStringSecret):
+		if err = values.Require(ConnectionStringID, ConnectionStringSecret); err != nil {
+			return nil, err
+		}
+
+		cs.ID = values.Get(ConnectionStringID)
+		keyName = ConnectionStringSecret
+
+	default:
+		if err = values.Require(ConnectionStringAccessKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if cs.Key, err = crypto.DecodeKey(values.Get(keyName)); err != nil {
+		return nil, &connstr.Error{Key: keyName, Err: ErrDecodingKey}
+	}
+
+	return cs, nil
+}
+
+// Signer returns a request signer for the connection string's key, preset
+// for App Configuration if the connection string has an ID. The signer shares
+// the connection string's key, so closing the signer destroys the key.
+func (c *ConnectionString) Signer() (*Signer, error) {
+	signer, err := crypto.NewKeySigner(c.Key)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	return NewSigner(signer, c.options()...)
+}
+
+// options returns the signer options the connection string requires.
+func (c *ConnectionString) options() []Option {
+	if c.ID == "" {
+		return nil
+	}
+
+	return []Option{WithCredential(c.ID)}
+}
+
+// NewSignerFromConnectionString returns a request signer for the key in the
+// connection string, along with the parsed connection string which provides
+// the endpoint to send requests to.
+func NewSignerFromConnectionString(connectionString string) (*Signer, *ConnectionString, error) {
+	cs, err := ParseConnectionString(connectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := newSignerWithKey(cs.Key, cs.options()...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, cs, nil
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package hmacauth provides the Azure HMAC-SHA256 request signing scheme used
+// by Azure Communication Services and Azure App Configuration.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/communication-services/tutorials/hmac-header-tutorial
+// Refer: https://docs.microsoft.com/en-us/azure/azure-app-configuration/rest-api-authentication-hmac
+package hmacauth
+
+import (
+	// Standard Library Imports
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderMSDate        = "x-ms-date"
+	HeaderContentSHA256 = "x-ms-content-sha256"
+	HeaderHost          = "host"
+
+	scheme = "HMAC-SHA256"
+)
+
+// signedHeaders are the headers included in the string-to-sign, in order.
+var signedHeaders = []string{HeaderMSDate, HeaderHost, HeaderContentSHA256}
+
+var (
+	ErrSignerRequired  = errors.New("a signer must be provided to sign requests")
+	ErrDecodingKey     = errors.New("error decoding access key, must be base64 encoded")
+	ErrCredentialEmpty = errors.New("credential must not be empty")
+)
+
+// Signer authorizes requests with the Azure HMAC-SHA256 scheme.
+type Signer struct {
+	credential string
+	signer     crypto.Signer
+	now        func() time.Time
+}
+
+// Option configures a Signer.
+type Option func(s *Signer) error
+
+// NewSigner returns a HMAC-SHA256 request signer, where signing is delegated
+// to the provided signer. Without options, the Authorization header is in the
+// form Azure Communication Services expects.
+func NewSigner(signer crypto.Signer, opts ...Option) (*Signer, error) {
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	s := &Signer{
+		signer: signer,
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// NewSignerFromKey returns a HMAC-SHA256 request signer which signs with the
+// base64 encoded access key or secret.
+func NewSignerFromKey(key string, opts ...Option) (*Signer, error) {
+	k, err := crypto.DecodeKey(key)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	return newSignerWithKey(k, opts...)
+}
+
+// newSignerWithKey returns a request signer which takes ownership of the key,
+// destroying it on error.
+func newSignerWithKey(key *crypto.Key, opts ...Option) (*Signer, error) {
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	s, err := NewSigner(signer, opts...)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewCommunicationSigner returns a request signer preset for Azure
+// Communication Services, signing with the base64 encoded access key.
+func NewCommunicationSigner(accessKey string) (*Signer, error) {
+	return NewSignerFromKey(accessKey)
+}
+
+// NewAppConfigurationSigner returns a request signer preset for Azure App
+// Configuration, signing with the access key's ID and base64 encoded secret.
+func NewAppConfigurationSigner(id string, secret string) (*Signer, error) {
+	return NewSignerFromKey(secret, WithCredential(id))
+}
+
+// WithCredential includes the access key ID as the Credential in the
+// Authorization header, as required by Azure App Configuration.
+func WithCredential(id string) Option {
+	return func(s *Signer) error {
+		if strings.TrimSpace(id) == "" {
+			return ErrCredentialEmpty
+		}
+
+		s.credential = id
+
+		return nil
+	}
+}
+
+// Close destroys the access key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// SignRequest authorizes the request in place, setting the x-ms-date header if
+// it has not been provided and the x-ms-content-sha256 header, then setting
+// the Authorization header. The request body is read to be hashed, and is
+// replaced so it can still be sent.
+func (s *Signer) SignRequest(req *http.Request) error {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	if req.Header.Get(HeaderMSDate) == "" {
+		req.Header.Set(HeaderMSDate, aztime.ToRFC1123(s.now()))
+	}
+
+	contentHash, err := hashBody(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderContentSHA256, contentHash)
+
+	signature, err := s.signer.Sign(req.Context(), []byte(StringToSign(req)))
+	if err != nil {
+		return err
+	}
+
+	auth := scheme + " "
+	if s.credential != "" {
+		auth += "Credential=" + s.credential + "&"
+	}
+	auth += "SignedHeaders=" + strings.Join(signedHeaders, ";") + "&Signature=" + signature
+
+	req.Header.Set(HeaderAuthorization, auth)
+
+	return nil
+}
+
+// StringToSign returns the string-to-sign for a request that has had its
+// x-ms-date and x-ms-content-sha256 headers set, which is useful for
+// debugging authorization failures.
+func StringToSign(req *http.Request) string {
+	return strings.ToUpper(req.Method) + "\n" +
+		req.URL.RequestURI() + "\n" +
+		req.Header.Get(HeaderMSDate) + ";" + host(req) + ";" + req.Header.Get(HeaderContentSHA256)
+}
+
+// host returns the host the request is sent to, as set in the Host header.
+func host(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+
+	return req.URL.Host
+}
+
+// hashBody returns the base64 encoded SHA-256 hash of the request body,
+// replacing the body so it can be read again when the request is sent.
+func hashBody(req *http.Request) (string, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return "", err
+		}
+		_ = req.Body.Close()
+
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	hash := sha256.Sum256(body)
+
+	return base64.StdEncoding.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hmacauth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/sassy/connstr"
+)
+
+const testKey = "c2Fzc3ktaG1hYy1rZXktMDEyMzQ1Njc4OWFiY2RlZg=="
+
+func TestSigner_SignRequest(t *testing.T) {
+	tests := []struct {
+		name              string
+		connectionString  string
+		method            string
+		url               string
+		body              string
+		wantContentHash   string
+		wantAuthorization string
+	}{
+		{
+			name:              "Should sign a Communication Services request with a body",
+			connectionString:  "endpoint=https://sassy.communication.azure.com/;accesskey=" + testKey,
+			method:            http.MethodPost,
+			url:               "https://sassy.communication.azure.com/identities?api-version=2021-03-07",
+			body:              `{"createTokenWithScopes":["chat"]}`,
+			wantContentHash:   "WTRvgEjjVd+bvyKw3WgXgDkU81aV8FWq+4/BE+he0+A=",
+			wantAuthorization: "HMAC-SHA256 SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature=QES9JKjY+uFwOW5yeygRvsSVWrK+1h+IoeZgPcH9Y+M=",
+		},
+		{
+			name:              "Should sign an App Configuration request with an escaped path",
+			connectionString:  "Endpoint=https://sassy.azconfig.io;Id=sassy-id;Secret=" + testKey,
+			method:            http.MethodGet,
+			url:               "https://sassy.azconfig.io/kv/app%3Acolor?label=prod",
+			wantContentHash:   "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+			wantAuthorization: "HMAC-SHA256 Credential=sassy-id&SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature=V4zUTrl0+VgfrTZV8l5WXz1VUQXKXOGmcMoYoVUBedo=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, err := NewSignerFromConnectionString(tt.connectionString)
+			if err != nil {
+				t.Fatalf("NewSignerFromConnectionString() error = %v", err)
+			}
+			defer s.Close()
+			s.now = func() time.Time { return time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC) }
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequest(tt.method, tt.url, body)
+			if err != nil {
+				t.Fatalf("http.NewRequest() error = %v", err)
+			}
+
+			if err = s.SignRequest(req); err != nil {
+				t.Fatalf("SignRequest() error = %v", err)
+			}
+
+			if got := req.Header.Get(HeaderContentSHA256); got != tt.wantContentHash {
+				t.Errorf("SignRequest() x-ms-content-sha256\ngot:  = %v\nwant: %v\n", got, tt.wantContentHash)
+			}
+
+			if got := req.Header.Get(HeaderAuthorization); got != tt.wantAuthorization {
+				t.Errorf("SignRequest()\ngot:  = %v\nwant: %v\n", got, tt.wantAuthorization)
+			}
+
+			if req.Body != nil {
+				if sent, _ := io.ReadAll(req.Body); string(sent) != tt.body {
+					t.Errorf("SignRequest() body\ngot:  = %v\nwant: %v\n", string(sent), tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		wantID           string
+		wantErrKey       string
+		wantErr          error
+	}{
+		{
+			name:             "Should parse a Communication Services connection string",
+			connectionString: "endpoint=https://sassy.communication.azure.com/;accesskey=" + testKey,
+		},
+		{
+			name:             "Should parse an App Configuration connection string",
+			connectionString: "Endpoint=https://sassy.azconfig.io;Id=sassy-id;Secret=" + testKey,
+			wantID:           "sassy-id",
+		},
+		{
+			name:             "Should error on a secret without an ID",
+			connectionString: "Endpoint=https://sassy.azconfig.io;Secret=" + testKey,
+			wantErrKey:       ConnectionStringID,
+			wantErr:          connstr.ErrMissingKey,
+		},
+		{
+			name:             "Should error on both an access key and a secret",
+			connectionString: "Endpoint=https://sassy.azconfig.io;Id=sassy-id;Secret=" + testKey + ";AccessKey=" + testKey,
+			wantErrKey:       ConnectionStringSecret,
+			wantErr:          ErrAccessKeyAndSecret,
+		},
+		{
+			name:             "Should error on a http endpoint",
+			connectionString: "endpoint=http://sassy.communication.azure.com/;accesskey=" + testKey,
+			wantErrKey:       ConnectionStringEndpoint,
+			wantErr:          ErrInvalidEndpoint,
+		},
+		{
+			name:             "Should error on a key that isn't base64 encoded",
+			connectionString: "endpoint=https://sassy.communication.azure.com/;accesskey=not base64",
+			wantErrKey:       ConnectionStringAccessKey,
+			wantErr:          ErrDecodingKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionString(tt.connectionString)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseConnectionString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var csErr *connstr.Error
+				if !errors.As(err, &csErr) || csErr.Key != tt.wantErrKey {
+					t.Errorf("ParseConnectionString()\ngot:  = %v\nwant: key %v\n", err, tt.wantErrKey)
+				}
+				return
+			}
+
+			if got.ID != tt.wantID {
+				t.Errorf("ParseConnectionString() ID\ngot:  = %v\nwant: %v\n", got.ID, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package canonical canonicalizes the query parameters, headers and content
+// length of requests, as signed by Storage Shared Key and the Azure Batch
+// service.
+package canonical
+
+import (
+	// Standard Library Imports
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query returns each query parameter on its own line, preceded by a new line,
+// with names lowercased and sorted and values sorted and comma separated, as
+// appended to the canonicalized resource by Shared Key and the Azure Batch
+// service.
+func Query(query url.Values) string {
+	canonical := map[string][]string{}
+	for name, values := range query {
+		name = strings.ToLower(name)
+		canonical[name] = append(canonical[name], values...)
+	}
+
+	names := make([]string, 0, len(canonical))
+	for name := range canonical {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		values := canonical[name]
+		sort.Strings(values)
+
+		b.WriteString("\n" + name + ":" + strings.Join(values, ","))
+	}
+
+	return b.String()
+}
+
+// Headers returns the lowercased, sorted headers with the given prefix, each
+// terminated by a new line, with whitespace in values unfolded, for example,
+// the x-ms- headers of Shared Key or the ocp- headers of the Azure Batch
+// service.
+func Headers(header http.Header, prefix string) string {
+	canonical := map[string][]string{}
+	for name, values := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		for _, value := range values {
+			canonical[name] = append(canonical[name], strings.Join(strings.Fields(value), " "))
+		}
+	}
+
+	names := make([]string, 0, len(canonical))
+	for name := range canonical {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(canonical[name], ",") + "\n")
+	}
+
+	return b.String()
+}
+
+// ContentLength returns the request's content length, or an empty string if
+// there is no content, as required for version 2015-02-21 and later.
+func ContentLength(req *http.Request) string {
+	if req.ContentLength > 0 {
+		return strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	if length := req.Header.Get("Content-Length"); length != "0" {
+		return length
+	}
+
+	return ""
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sastoken implements the shared access signature tokens common to
+// Service Bus, Event Hubs, Relay, Notification Hubs and IoT Hub, which differ
+// only in how token values are percent-encoded and how keys are decoded.
+package sastoken
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	// Prefix prefixes every shared access signature token.
+	Prefix = "SharedAccessSignature "
+
+	paramResource  = "sr"
+	paramSignature = "sig"
+	paramExpiry    = "se"
+	paramKeyName   = "skn"
+)
+
+var (
+	ErrSignerRequired    = errors.New("a signer must be provided to sign tokens")
+	ErrResourceURIEmpty  = errors.New("resource URI must not be empty")
+	ErrExpiryRequired    = errors.New("token expiry must be provided")
+	ErrInvalidToken      = errors.New("invalid shared access signature token")
+	ErrKeyNameMismatch   = errors.New("token was not signed with the signer's shared access policy")
+	ErrSignatureMismatch = errors.New("token signature does not match")
+	ErrTokenExpired      = errors.New("token has expired")
+)
+
+// Format holds the rules which differ between the services using these tokens.
+type Format struct {
+	// Escape percent-encodes the resource URI, signature and key name.
+	Escape func(value string) string
+	// DecodeKey returns the key tokens are signed with from a shared access
+	// key, as shown in the Azure portal.
+	DecodeKey func(key string) (*crypto.Key, error)
+	// ErrKey is returned when a key can't be decoded or signed with.
+	ErrKey error
+	// ErrKeyNameEmpty, if set, requires signers to have a key name.
+	ErrKeyNameEmpty error
+}
+
+// Token is a shared access signature token.
+type Token struct {
+	// Resource is the URI of the resource the token grants access to.
+	Resource string
+	// KeyName is the name of the shared access policy the token was signed
+	// with. It is empty for IoT Hub tokens signed with a device or module key.
+	KeyName string
+	// Expiry is when the token stops being valid, at a resolution of seconds.
+	Expiry time.Time
+	// Signature is the base64 encoded HMAC-SHA256 signature.
+	Signature string
+
+	// encodedResource preserves the resource as it was encoded in a parsed
+	// token, as the signature is computed over the encoded form.
+	encodedResource string
+}
+
+// Parse parses a shared access signature token, with or without the
+// "SharedAccessSignature " prefix.
+func Parse(token string) (*Token, error) {
+	token = strings.TrimPrefix(strings.TrimSpace(token), Prefix)
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	t := &Token{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(token, "&") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || seen[key] {
+			return nil, ErrInvalidToken
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case paramResource:
+			t.encodedResource = value
+			t.Resource, err = url.QueryUnescape(value)
+
+		case paramSignature:
+			// Signatures are base64 encoded, so a literal '+' must not be
+			// decoded as a space.
+			t.Signature, err = url.PathUnescape(value)
+
+		case paramExpiry:
+			t.Expiry, err = aztime.ParseUnix(value)
+
+		case paramKeyName:
+			t.KeyName, err = url.QueryUnescape(value)
+
+		default:
+			err = ErrInvalidToken
+		}
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	if t.Resource == "" || t.Signature == "" || t.Expiry.IsZero() {
+		return nil, ErrInvalidToken
+	}
+
+	return t, nil
+}
+
+// String returns the token with its values encoded in the given format.
+func (t *Token) String(f Format) string {
+	token := Prefix +
+		paramResource + "=" + t.resource(f) +
+		"&" + paramSignature + "=" + f.Escape(t.Signature) +
+		"&" + paramExpiry + "=" + aztime.ToUnix(t.Expiry)
+
+	if t.KeyName != "" {
+		token += "&" + paramKeyName + "=" + f.Escape(t.KeyName)
+	}
+
+	return token
+}
+
+// StringToSign returns the message the token's signature is computed over.
+func (t *Token) StringToSign(f Format) string {
+	return t.resource(f) + "\n" + aztime.ToUnix(t.Expiry)
+}
+
+// Expired reports whether the token has expired at the given time.
+func (t *Token) Expired(now time.Time) bool {
+	return !now.Before(t.Expiry)
+}
+
+// resource returns the encoded resource URI.
+func (t *Token) resource(f Format) string {
+	if t.encodedResource != "" {
+		return t.encodedResource
+	}
+
+	return f.Escape(t.Resource)
+}
+
+// Signer generates and verifies shared access signature tokens.
+type Signer struct {
+	format  Format
+	keyName string
+	signer  crypto.Signer
+
+	// Now returns the time tokens are verified at.
+	Now func() time.Time
+}
+
+// NewSigner returns a token signer for the named shared access policy, where
+// signing is delegated to the provided signer.
+func NewSigner(f Format, keyName string, signer crypto.Signer) (*Signer, error) {
+	if f.ErrKeyNameEmpty != nil && strings.TrimSpace(keyName) == "" {
+		return nil, f.ErrKeyNameEmpty
+	}
+
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	return &Signer{
+		format:  f,
+		keyName: keyName,
+		signer:  signer,
+		Now:     time.Now,
+	}, nil
+}
+
+// NewSignerFromKey returns a token signer which signs with the key, decoded
+// as per the format.
+func NewSignerFromKey(f Format, keyName string, key string) (*Signer, error) {
+	k, err := f.DecodeKey(key)
+	if err != nil {
+		return nil, f.ErrKey
+	}
+
+	return NewSignerWithKey(f, keyName, k)
+}
+
+// NewSignerWithKey returns a token signer which takes ownership of the key,
+// destroying it on error.
+func NewSignerWithKey(f Format, keyName string, key *crypto.Key) (*Signer, error) {
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, f.ErrKey
+	}
+
+	s, err := NewSigner(f, keyName, signer)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// KeyName returns the name of the shared access policy tokens are signed
+// with.
+func (s *Signer) KeyName() string {
+	return s.keyName
+}
+
+// Close destroys the signer's key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// Token returns a token granting access to the resource URI until expiry,
+// passing the context through to the signer.
+func (s *Signer) Token(ctx context.Context, resourceURI string, expiry time.Time) (*Token, error) {
+	if strings.TrimSpace(resourceURI) == "" {
+		return nil, ErrResourceURIEmpty
+	}
+
+	if expiry.IsZero() {
+		return nil, ErrExpiryRequired
+	}
+
+	t := &Token{
+		Resource: resourceURI,
+		KeyName:  s.keyName,
+		Expiry:   expiry.Truncate(time.Second).UTC(),
+	}
+
+	signature, err := crypto.SignWithExpiry(ctx, s.signer, []byte(t.StringToSign(s.format)), t.Expiry)
+	if err != nil {
+		return nil, err
+	}
+	t.Signature = signature
+
+	return t, nil
+}
+
+// Verify returns ErrKeyNameMismatch, ErrSignatureMismatch or ErrTokenExpired
+// if the token wasn't signed by the signer or has expired. Signers holding
+// several keys, such as a crypto.Keyring, accept a signature from any key.
+func (s *Signer) Verify(ctx context.Context, token *Token) error {
+	if token == nil {
+		return ErrInvalidToken
+	}
+
+	if token.KeyName != s.keyName {
+		return ErrKeyNameMismatch
+	}
+
+	_, ok, err := crypto.Verify(ctx, s.signer, []byte(token.StringToSign(s.format)), token.Signature)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrSignatureMismatch
+	}
+
+	if token.Expired(s.Now()) {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package iothub
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+// Connection string keys.
+// Refer: https://docs.microsoft.com/en-us/azure/iot-hub/iot-hub-dev-guide-sas
+const (
+	ConnectionStringHostName              = "HostName"
+	ConnectionStringDeviceID              = "DeviceId"
+	ConnectionStringModuleID              = "ModuleId"
+	ConnectionStringSharedAccessKeyName   = "SharedAccessKeyName"
+	ConnectionStringSharedAccessKey       = "SharedAccessKey"
+	ConnectionStringSharedAccessSignature = "SharedAccessSignature"
+	ConnectionStringGatewayHostName       = "GatewayHostName"
+)
+
+// MQTTAPIVersion is the API version sent in the MQTT username.
+const MQTTAPIVersion = "2021-04-12"
+
+var (
+	ErrKeyAndSAS           = errors.New("a shared access key and a shared access signature must not both be provided")
+	ErrDeviceAndPolicy     = errors.New("a device ID and a shared access key name must not both be provided")
+	ErrModuleWithoutDevice = errors.New("a module ID requires a device ID")
+)
+
+// ConnectionString holds a parsed IoT Hub device, module or shared access
+// policy connection string.
+type ConnectionString struct {
+	HostName string
+	// DeviceID is set for device and module connection strings.
+	DeviceID string
+	// ModuleID is set for module connection strings.
+	ModuleID string
+	// SharedAccessKeyName is set for hub-level shared access policy
+	// connection strings.
+	SharedAccessKeyName string
+	// SharedAccessKey is nil if the connection string uses a shared access
+	// signature instead of a shared access key.
+	SharedAccessKey       *crypto.Key
+	SharedAccessSignature *Token
+	// GatewayHostName is set when connecting via an IoT Edge gateway.
+	GatewayHostName string
+}
+
+// ParseConnectionString parses an IoT Hub connection string, supporting
+// device, module and hub-level shared access policy connection strings.
+//
+// Errors are returned as a *connstr.Error, naming the malformed part of the
+// connection string.
+func ParseConnectionString(connectionString string) (*ConnectionString, error) {
+	values, err := connstr.Parse(
+		connectionString,
+		ConnectionStringHostName,
+		ConnectionStringDeviceID,
+		ConnectionStringModuleID,
+		ConnectionStringSharedAccessKeyName,
+		ConnectionStringSharedAccessKey,
+		ConnectionStringSharedAccessSignature,
+		ConnectionStringGatewayHostName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = values.Require(ConnectionStringHostName); err != nil {
+		return nil, err
+	}
+
+	cs := &ConnectionString{
+		HostName:            values.Get(ConnectionStringHostName),
+		DeviceID:            values.Get(ConnectionStringDeviceID),
+		ModuleID:            values.Get(ConnectionStringModuleID),
+		SharedAccessKeyName: values.Get(ConnectionStringSharedAccessKeyName),
+		GatewayHostName:     values.Get(ConnectionStringGatewayHostName),
+	}
+
+	if cs.DeviceID != "" && cs.SharedAccessKeyName != "" {
+		return nil, &connstr.Error{Key: ConnectionStringSharedAccessKeyName, Err: ErrDeviceAndPolicy}
+	}
+
+	if cs.ModuleID != "" && cs.DeviceID == "" {
+		return nil, &connstr.Error{Key: ConnectionStringDeviceID, Err: ErrModuleWithoutDevice}
+	}
+
+	hasKey := values.Has(ConnectionStringSharedAccessKey)
+	hasSAS := values.Has(ConnectionStringSharedAccessSignature)
+	switch {
+	case hasKey && hasSAS:
+		return nil, &connstr.Error{Key: ConnectionStringSharedAccessSignature, Err: ErrKeyAndSAS}
+
+	case hasKey:
+		if cs.DeviceID == "" {
+			if err = values.Require(ConnectionStringSharedAccessKeyName); err != nil {
+				return nil, err
+			}
+		}
+
+		if cs.SharedAccessKey, err = crypto.DecodeKey(values.Get(ConnectionStringSharedAccessKey)); err != nil {
+			return nil, &connstr.Error{Key: ConnectionStringSharedAccessKey, Err: ErrDecodingKey}
+		}
+
+	case hasSAS:
+		if cs.SharedAccessSignature, err = ParseToken(values.Get(ConnectionStringSharedAccessSignature)); err != nil {
+			return nil, &connstr.Error{Key: ConnectionStringSharedAccessSignature, Err: err}
+		}
+
+	default:
+		return nil, &connstr.Error{Key: ConnectionStringSharedAccessKey, Err: connstr.ErrMissingKey}
+	}
+
+	return cs, nil
+}
+
+// ResourceURI returns the resource URI tokens for the connection string are
+// scoped to, being the module, the device, or the hub for shared access
+// policies.
+func (c *ConnectionString) ResourceURI() string {
+	switch {
+	case c.ModuleID != "":
+		uri, _ := ModuleURI(c.HostName, c.DeviceID, c.ModuleID)
+		return uri
+
+	case c.DeviceID != "":
+		uri, _ := DeviceURI(c.HostName, c.DeviceID)
+		return uri
+
+	default:
+		return c.HostName
+	}
+}
+
+// Signer returns a token signer for the connection string's key. The signer
+// shares the connection string's key, so closing the signer destroys the key.
+func (c *ConnectionString) Signer() (*Signer, error) {
+	if c.SharedAccessKey == nil {
+		return nil, ErrDecodingKey
+	}
+
+	signer, err := crypto.NewKeySigner(c.SharedAccessKey)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	return NewSigner(c.SharedAccessKeyName, signer)
+}
+
+// NewSignerFromConnectionString returns a token signer for the key in the
+// connection string, along with the parsed connection string which provides
+// the resource URI to sign.
+func NewSignerFromConnectionString(connectionString string) (*Signer, *ConnectionString, error) {
+	cs, err := ParseConnectionString(connectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cs.SharedAccessKey == nil {
+		return nil, nil, &connstr.Error{Key: ConnectionStringSharedAccessKey, Err: connstr.ErrMissingKey}
+	}
+
+	s, err := newSignerWithKey(cs.SharedAccessKeyName, cs.SharedAccessKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, cs, nil
+}
+
+// MQTTCredentials holds the client ID, username and password a device or
+// module connects to IoT Hub's MQTT endpoint with.
+type MQTTCredentials struct {
+	ClientID string
+	Username string
+	// Password is the SAS token.
+	Password string
+}
+
+// MQTTCredentials returns the MQTT credentials for the connection string's
+// device or module, with a SAS token valid until expiry.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/iot-hub/iot-hub-mqtt-support
+func (c *ConnectionString) MQTTCredentials(ctx context.Context, expiry time.Time) (*MQTTCredentials, error) {
+	if c.DeviceID == "" {
+		return nil, ErrDeviceIDEmpty
+	}
+
+	var token *Token
+	switch {
+	case c.SharedAccessSignature != nil:
+		token = c.SharedAccessSignature
+
+	default:
+		signer, err := c.Signer()
+		if err != nil {
+			return nil, err
+		}
+
+		if token, err = signer.TokenContext(ctx, c.ResourceURI(), expiry); err != nil {
+			return nil, err
+		}
+	}
+
+	clientID := c.DeviceID
+	if c.ModuleID != "" {
+		clientID += "/" + c.ModuleID
+	}
+
+	return &MQTTCredentials{
+		ClientID: clientID,
+		Username: c.HostName + "/" + clientID + "/?api-version=" + MQTTAPIVersion,
+		Password: token.String(),
+	}, nil
+}
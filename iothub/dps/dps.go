@@ -0,0 +1,174 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dps provides IoT Hub Device Provisioning Service symmetric key
+// group enrollment device key derivation and registration SAS tokens.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/iot-dps/concepts-symmetric-key-attestation
+package dps
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/iothub"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	// GlobalEndpoint is the global device provisioning endpoint.
+	GlobalEndpoint = "global.azure-devices-provisioning.net"
+	// KeyName is the shared access key name registration tokens are signed
+	// with.
+	KeyName = "registration"
+
+	registrationsPath = "registrations"
+	// maxRegistrationIDLength is the longest registration ID DPS accepts.
+	maxRegistrationIDLength = 128
+)
+
+var (
+	ErrIDScopeEmpty          = errors.New("ID scope must not be empty")
+	ErrRegistrationIDEmpty   = errors.New("registration ID must not be empty")
+	ErrInvalidRegistrationID = errors.New("registration ID must be at most 128 alphanumeric, '-', '.', '_' or ':' characters, ending in an alphanumeric or '-'")
+	ErrSignerRequired        = errors.New("a signer must be provided to derive device keys")
+	ErrDecodingEnrollmentKey = errors.New("error decoding enrollment group key, must be base64 encoded")
+	ErrDecodingDeviceKey     = errors.New("error decoding device key, must be base64 encoded")
+)
+
+// ValidateRegistrationID reports whether the registration ID is acceptable to
+// DPS, returning ErrRegistrationIDEmpty or ErrInvalidRegistrationID if not.
+func ValidateRegistrationID(registrationID string) error {
+	if registrationID == "" {
+		return ErrRegistrationIDEmpty
+	}
+
+	if len(registrationID) > maxRegistrationIDLength {
+		return ErrInvalidRegistrationID
+	}
+
+	for i, c := range registrationID {
+		alphanumeric := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		switch {
+		case alphanumeric, c == '-':
+			continue
+
+		case (c == '.' || c == '_' || c == ':') && i < len(registrationID)-1:
+			continue
+
+		default:
+			return ErrInvalidRegistrationID
+		}
+	}
+
+	return nil
+}
+
+// DeriveKey derives a device's symmetric key from the enrollment group key
+// held by the signer, as HMAC-SHA256(groupKey, registrationID). The derived
+// key is base64 encoded, ready to be used as the device's key.
+//
+// Derivation is deterministic, so the same registration ID always produces
+// the same device key for the enrollment group.
+func DeriveKey(ctx context.Context, groupKey crypto.Signer, registrationID string) (string, error) {
+	if groupKey == nil {
+		return "", ErrSignerRequired
+	}
+
+	if err := ValidateRegistrationID(registrationID); err != nil {
+		return "", err
+	}
+
+	return groupKey.Sign(ctx, []byte(registrationID))
+}
+
+// DeriveKeyFromGroupKey derives a device's symmetric key from the base64
+// encoded enrollment group key.
+func DeriveKeyFromGroupKey(groupKey string, registrationID string) (string, error) {
+	signer, err := NewGroupKeySigner(groupKey)
+	if err != nil {
+		return "", err
+	}
+	if closer, ok := signer.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	return DeriveKey(context.Background(), signer, registrationID)
+}
+
+// NewGroupKeySigner returns a signer holding the base64 encoded enrollment
+// group key, to derive many device keys without decoding the group key each
+// time. The signer implements io.Closer to destroy the group key.
+func NewGroupKeySigner(groupKey string) (crypto.Signer, error) {
+	key, err := crypto.DecodeKey(groupKey)
+	if err != nil {
+		return nil, ErrDecodingEnrollmentKey
+	}
+
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrDecodingEnrollmentKey
+	}
+
+	return signer, nil
+}
+
+// RegistrationURI returns the resource URI registration tokens are scoped to,
+// in the form {idScope}/registrations/{registrationID}.
+func RegistrationURI(idScope string, registrationID string) (string, error) {
+	if strings.TrimSpace(idScope) == "" {
+		return "", ErrIDScopeEmpty
+	}
+
+	if err := ValidateRegistrationID(registrationID); err != nil {
+		return "", err
+	}
+
+	return idScope + "/" + registrationsPath + "/" + registrationID, nil
+}
+
+// NewSigner returns a registration token signer, where signing with the
+// device key is delegated to the provided signer.
+func NewSigner(deviceKey crypto.Signer) (*iothub.Signer, error) {
+	return iothub.NewSigner(KeyName, deviceKey)
+}
+
+// NewSignerFromKey returns a registration token signer which signs with the
+// base64 encoded device key, for example, a key returned by DeriveKey.
+func NewSignerFromKey(deviceKey string) (*iothub.Signer, error) {
+	s, err := iothub.NewSignerFromKey(KeyName, deviceKey)
+	if errors.Is(err, iothub.ErrDecodingKey) {
+		return nil, ErrDecodingDeviceKey
+	}
+
+	return s, err
+}
+
+// Token returns a registration token for the device, valid until expiry,
+// used to authenticate with DPS when registering.
+func Token(ctx context.Context, signer *iothub.Signer, idScope string, registrationID string, expiry time.Time) (*iothub.Token, error) {
+	resourceURI, err := RegistrationURI(idScope, registrationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.TokenContext(ctx, resourceURI, expiry)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dps
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testGroupKey = "c2Fzc3ktZ3JvdXAta2V5LTAxMjM0NTY3ODlhYmNkZWY="
+	testIDScope  = "0ne00000001"
+)
+
+func TestDeriveKeyFromGroupKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		registrationID string
+		want           string
+		wantErr        error
+	}{
+		{
+			name:           "Should derive a device key",
+			registrationID: "sensor-001",
+			want:           "mr/xakbHHTfUIl6OCBM4tL6O/dJspgpa/Ny2FT+eOyU=",
+		},
+		{
+			name:           "Should derive a different key per device",
+			registrationID: "sensor-002",
+			want:           "uY3gwNdn5B6OJSxrAFObEEigQkQH0hUSrBAZQof4ItQ=",
+		},
+		{
+			name:           "Should error on an empty registration ID",
+			registrationID: "",
+			wantErr:        ErrRegistrationIDEmpty,
+		},
+		{
+			name:           "Should error on a registration ID ending in a '.'",
+			registrationID: "sensor.",
+			wantErr:        ErrInvalidRegistrationID,
+		},
+		{
+			name:           "Should error on a registration ID containing a '/'",
+			registrationID: "sensor/001",
+			wantErr:        ErrInvalidRegistrationID,
+		},
+		{
+			name:           "Should error on a registration ID over 128 characters",
+			registrationID: strings.Repeat("a", 129),
+			wantErr:        ErrInvalidRegistrationID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DeriveKeyFromGroupKey(testGroupKey, tt.registrationID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeriveKeyFromGroupKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("DeriveKeyFromGroupKey()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	deviceKey, err := DeriveKeyFromGroupKey(testGroupKey, "sensor-001")
+	if err != nil {
+		t.Fatalf("DeriveKeyFromGroupKey() error = %v", err)
+	}
+
+	signer, err := NewSignerFromKey(deviceKey)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey() error = %v", err)
+	}
+	defer signer.Close()
+
+	token, err := Token(context.Background(), signer, testIDScope, "sensor-001", time.Unix(1639303810, 0))
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	want := "SharedAccessSignature sr=0ne00000001%2Fregistrations%2Fsensor-001" +
+		"&sig=9RfccQWZBkxY0qeE0Ct1zByAlyDu0pKXdHrlzjoOc8M%3D" +
+		"&se=1639303810&skn=registration"
+	if got := token.String(); got != want {
+		t.Errorf("Token()\ngot:  = %v\nwant: %v\n", got, want)
+	}
+}
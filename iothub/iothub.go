@@ -0,0 +1,194 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package iothub provides generation, parsing and verification of IoT Hub
+// SAS tokens for devices, modules and hub-level shared access policies.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/iot-hub/iot-hub-dev-guide-sas
+package iothub
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/internal/sastoken"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	// TokenPrefix prefixes every IoT Hub SAS token.
+	TokenPrefix = sastoken.Prefix
+
+	devicesPath = "devices"
+	modulesPath = "modules"
+)
+
+var (
+	ErrHostNameEmpty     = errors.New("IoT Hub host name must not be empty")
+	ErrDeviceIDEmpty     = errors.New("device ID must not be empty")
+	ErrModuleIDEmpty     = errors.New("module ID must not be empty")
+	ErrSignerRequired    = sastoken.ErrSignerRequired
+	ErrDecodingKey       = errors.New("error decoding shared access key, must be base64 encoded")
+	ErrResourceURIEmpty  = sastoken.ErrResourceURIEmpty
+	ErrExpiryRequired    = sastoken.ErrExpiryRequired
+	ErrInvalidToken      = sastoken.ErrInvalidToken
+	ErrKeyNameMismatch   = sastoken.ErrKeyNameMismatch
+	ErrSignatureMismatch = sastoken.ErrSignatureMismatch
+	ErrTokenExpired      = sastoken.ErrTokenExpired
+)
+
+// format encodes token values the way the IoT Hub samples and SDKs do and
+// base64 decodes keys. Device and module keys have no key name.
+var format = sastoken.Format{
+	Escape:    encodeURIComponent,
+	DecodeKey: crypto.DecodeKey,
+	ErrKey:    ErrDecodingKey,
+}
+
+// DeviceURI returns the resource URI of a device, in the form
+// {hostName}/devices/{deviceID}.
+func DeviceURI(hostName string, deviceID string) (string, error) {
+	if strings.TrimSpace(hostName) == "" {
+		return "", ErrHostNameEmpty
+	}
+
+	if strings.TrimSpace(deviceID) == "" {
+		return "", ErrDeviceIDEmpty
+	}
+
+	return hostName + "/" + devicesPath + "/" + deviceID, nil
+}
+
+// ModuleURI returns the resource URI of a device's module, in the form
+// {hostName}/devices/{deviceID}/modules/{moduleID}.
+func ModuleURI(hostName string, deviceID string, moduleID string) (string, error) {
+	deviceURI, err := DeviceURI(hostName, deviceID)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(moduleID) == "" {
+		return "", ErrModuleIDEmpty
+	}
+
+	return deviceURI + "/" + modulesPath + "/" + moduleID, nil
+}
+
+// Token is an IoT Hub shared access signature token, holding the URI of the
+// Resource it grants access to, without a scheme, for example,
+// {hub}.azure-devices.net/devices/{deviceID}, the KeyName of the shared access
+// policy it was signed with, which is empty for tokens signed with a device or
+// module key, its Expiry and the base64 encoded Signature.
+type Token sastoken.Token
+
+// ParseToken parses an IoT Hub shared access signature token, with or without
+// the "SharedAccessSignature " prefix.
+func ParseToken(token string) (*Token, error) {
+	t, err := sastoken.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*Token)(t), nil
+}
+
+// String implements Stringer, returning the token in the form used as the
+// MQTT password, AMQP put-token message and HTTPS Authorization header.
+func (t *Token) String() string {
+	return (*sastoken.Token)(t).String(format)
+}
+
+// StringToSign returns the message the token's signature is computed over.
+func (t *Token) StringToSign() string {
+	return (*sastoken.Token)(t).StringToSign(format)
+}
+
+// Expired reports whether the token has expired at the given time.
+func (t *Token) Expired(now time.Time) bool {
+	return (*sastoken.Token)(t).Expired(now)
+}
+
+// encodeURIComponent percent-encodes a token value the way the IoT Hub
+// samples and SDKs do, encoding spaces as %20 rather than '+'.
+func encodeURIComponent(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// Signer generates and verifies IoT Hub shared access signature tokens with a
+// device key, a module key or a hub-level shared access policy key.
+type Signer struct {
+	sas *sastoken.Signer
+}
+
+// NewSigner returns a token signer, where signing is delegated to the
+// provided signer. keyName is the name of the hub-level shared access policy,
+// and must be empty when signing with a device or module key.
+func NewSigner(keyName string, signer crypto.Signer) (*Signer, error) {
+	return newSigner(sastoken.NewSigner(format, strings.TrimSpace(keyName), signer))
+}
+
+// NewSignerFromKey returns a token signer which signs with the base64 encoded
+// device, module or shared access policy key.
+func NewSignerFromKey(keyName string, key string) (*Signer, error) {
+	return newSigner(sastoken.NewSignerFromKey(format, strings.TrimSpace(keyName), key))
+}
+
+// newSignerWithKey returns a token signer which takes ownership of the key,
+// destroying it on error.
+func newSignerWithKey(keyName string, key *crypto.Key) (*Signer, error) {
+	return newSigner(sastoken.NewSignerWithKey(format, strings.TrimSpace(keyName), key))
+}
+
+func newSigner(sas *sastoken.Signer, err error) (*Signer, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{sas: sas}, nil
+}
+
+// Close destroys the device, module or policy key, if held in memory.
+func (s *Signer) Close() error {
+	return s.sas.Close()
+}
+
+// Token returns a token granting access to the resource URI until expiry.
+func (s *Signer) Token(resourceURI string, expiry time.Time) (*Token, error) {
+	return s.TokenContext(context.Background(), resourceURI, expiry)
+}
+
+// TokenContext returns a token granting access to the resource URI until
+// expiry, passing the context through to the signer.
+func (s *Signer) TokenContext(ctx context.Context, resourceURI string, expiry time.Time) (*Token, error) {
+	t, err := s.sas.Token(ctx, resourceURI, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*Token)(t), nil
+}
+
+// Verify returns ErrKeyNameMismatch, ErrSignatureMismatch or ErrTokenExpired
+// if the token wasn't signed by the signer or has expired. Signers holding
+// several keys, such as a crypto.Keyring, accept a signature from any key.
+func (s *Signer) Verify(ctx context.Context, token *Token) error {
+	return s.sas.Verify(ctx, (*sastoken.Token)(token))
+}
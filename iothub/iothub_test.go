@@ -0,0 +1,234 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package iothub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const testKey = "c2Fzc3ktZGV2aWNlLWtleS0wMTIzNDU2Nzg5YWJjZGVm"
+
+var testExpiry = time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC)
+
+func TestSigner_Token(t *testing.T) {
+	tests := []struct {
+		name        string
+		keyName     string
+		resourceURI string
+		want        string
+	}{
+		{
+			name:        "Should encode spaces in device IDs as %20",
+			resourceURI: "sassy.azure-devices.net/devices/gateway 1",
+			want: "SharedAccessSignature sr=sassy.azure-devices.net%2Fdevices%2Fgateway%201" +
+				"&sig=oFewysj1nnpe40Tk1EJRMbTaADU%2BraThKWK5Vdksg8I%3D" +
+				"&se=1639303810",
+		},
+		{
+			name:        "Should sign a module URI",
+			resourceURI: "sassy.azure-devices.net/devices/gateway-1/modules/edgeHub",
+			want: "SharedAccessSignature sr=sassy.azure-devices.net%2Fdevices%2Fgateway-1%2Fmodules%2FedgeHub" +
+				"&sig=yoCXNEU%2Bas1tqr4JBb%2BcF7PDtBky0lUW9WNKY7KhZVo%3D" +
+				"&se=1639303810",
+		},
+		{
+			name:        "Should include the policy name for hub-level tokens",
+			keyName:     "iothubowner",
+			resourceURI: "sassy.azure-devices.net",
+			want: "SharedAccessSignature sr=sassy.azure-devices.net" +
+				"&sig=nYDCTrqo57IGtoIUM3pq36B2fEucjQT4Iv%2Bxp%2Fs7HrI%3D" +
+				"&se=1639303810&skn=iothubowner",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSignerFromKey(tt.keyName, testKey)
+			if err != nil {
+				t.Fatalf("NewSignerFromKey() error = %v", err)
+			}
+			defer s.Close()
+
+			token, err := s.Token(tt.resourceURI, testExpiry)
+			if err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+
+			if got := token.String(); got != tt.want {
+				t.Errorf("Token()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+
+			parsed, err := ParseToken(tt.want)
+			if err != nil {
+				t.Fatalf("ParseToken() error = %v", err)
+			}
+
+			s.sas.Now = func() time.Time { return testExpiry.Add(-time.Minute) }
+			if err = s.Verify(context.Background(), parsed); err != nil {
+				t.Errorf("Verify()\ngot:  = %v\nwant: %v\n", err, nil)
+			}
+		})
+	}
+}
+
+func TestSigner_Verify_Keyring(t *testing.T) {
+	const otherKey = "b3RoZXItZGV2aWNlLWtleS0wMTIzNDU2Nzg5YWJjZGVm"
+
+	token, err := ParseToken("SharedAccessSignature sr=sassy.azure-devices.net" +
+		"&sig=nYDCTrqo57IGtoIUM3pq36B2fEucjQT4Iv%2Bxp%2Fs7HrI%3D" +
+		"&se=1639303810&skn=iothubowner")
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr error
+	}{
+		{
+			name: "Should verify a token signed with the primary key",
+			keys: []string{testKey, otherKey},
+		},
+		{
+			name: "Should verify a token signed with the secondary key",
+			keys: []string{otherKey, testKey},
+		},
+		{
+			name:    "Should error if signed with neither key",
+			keys:    []string{otherKey, "dGhpcmQtZGV2aWNlLWtleQ=="},
+			wantErr: ErrSignatureMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring := crypto.NewKeyring()
+			for i, name := range []string{"primary", "secondary"} {
+				if err := keyring.AddBase64(name, tt.keys[i]); err != nil {
+					t.Fatalf("AddBase64() error = %v", err)
+				}
+			}
+
+			s, err := NewSigner("iothubowner", keyring)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			defer s.Close()
+			s.sas.Now = func() time.Time { return testExpiry.Add(-time.Minute) }
+
+			if err := s.Verify(context.Background(), token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify()\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		wantResourceURI  string
+		wantErrKey       string
+		wantErr          error
+	}{
+		{
+			name:             "Should parse a device connection string",
+			connectionString: "HostName=sassy.azure-devices.net;DeviceId=gateway-1;SharedAccessKey=" + testKey,
+			wantResourceURI:  "sassy.azure-devices.net/devices/gateway-1",
+		},
+		{
+			name:             "Should parse a module connection string",
+			connectionString: "HostName=sassy.azure-devices.net;DeviceId=gateway-1;ModuleId=edgeHub;SharedAccessKey=" + testKey,
+			wantResourceURI:  "sassy.azure-devices.net/devices/gateway-1/modules/edgeHub",
+		},
+		{
+			name:             "Should parse a shared access policy connection string",
+			connectionString: "HostName=sassy.azure-devices.net;SharedAccessKeyName=iothubowner;SharedAccessKey=" + testKey,
+			wantResourceURI:  "sassy.azure-devices.net",
+		},
+		{
+			name:             "Should ignore unknown keys",
+			connectionString: "HostName=sassy.azure-devices.net;DeviceId=gateway-1;SharedAccessKey=" + testKey + ";GatewayHostName=edge.example.com",
+			wantResourceURI:  "sassy.azure-devices.net/devices/gateway-1",
+		},
+		{
+			name:             "Should error on a policy key without a key name",
+			connectionString: "HostName=sassy.azure-devices.net;SharedAccessKey=" + testKey,
+			wantErrKey:       ConnectionStringSharedAccessKeyName,
+			wantErr:          connstr.ErrMissingKey,
+		},
+		{
+			name:             "Should error on a module without a device",
+			connectionString: "HostName=sassy.azure-devices.net;ModuleId=edgeHub;SharedAccessKey=" + testKey,
+			wantErrKey:       ConnectionStringDeviceID,
+			wantErr:          ErrModuleWithoutDevice,
+		},
+		{
+			name:             "Should error on a key that isn't base64 encoded",
+			connectionString: "HostName=sassy.azure-devices.net;DeviceId=gateway-1;SharedAccessKey=not base64",
+			wantErrKey:       ConnectionStringSharedAccessKey,
+			wantErr:          ErrDecodingKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionString(tt.connectionString)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseConnectionString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var csErr *connstr.Error
+				if !errors.As(err, &csErr) || csErr.Key != tt.wantErrKey {
+					t.Errorf("ParseConnectionString()\ngot:  = %v\nwant: key %v\n", err, tt.wantErrKey)
+				}
+				return
+			}
+
+			if uri := got.ResourceURI(); uri != tt.wantResourceURI {
+				t.Errorf("ResourceURI()\ngot:  = %v\nwant: %v\n", uri, tt.wantResourceURI)
+			}
+		})
+	}
+}
+
+func TestConnectionString_MQTTCredentials(t *testing.T) {
+	cs, err := ParseConnectionString("HostName=sassy.azure-devices.net;DeviceId=gateway-1;ModuleId=edgeHub;SharedAccessKey=" + testKey)
+	if err != nil {
+		t.Fatalf("ParseConnectionString() error = %v", err)
+	}
+
+	got, err := cs.MQTTCredentials(context.Background(), testExpiry)
+	if err != nil {
+		t.Fatalf("MQTTCredentials() error = %v", err)
+	}
+
+	want := MQTTCredentials{
+		ClientID: "gateway-1/edgeHub",
+		Username: "sassy.azure-devices.net/gateway-1/edgeHub/?api-version=2021-04-12",
+		Password: "SharedAccessSignature sr=sassy.azure-devices.net%2Fdevices%2Fgateway-1%2Fmodules%2FedgeHub" +
+			"&sig=yoCXNEU%2Bas1tqr4JBb%2BcF7PDtBky0lUW9WNKY7KhZVo%3D" +
+			"&se=1639303810",
+	}
+	if *got != want {
+		t.Errorf("MQTTCredentials()\ngot:  = %+v\nwant: %+v\n", *got, want)
+	}
+}
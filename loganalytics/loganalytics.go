@@ -0,0 +1,240 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package loganalytics provides Shared Key authorization of Log Analytics
+// HTTP Data Collector API requests.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/azure-monitor/logs/data-collector-api
+package loganalytics
+
+import (
+	// Standard Library Imports
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	HeaderAuthorization      = "Authorization"
+	HeaderContentType        = "Content-Type"
+	HeaderMSDate             = "x-ms-date"
+	HeaderLogType            = "Log-Type"
+	HeaderTimeGeneratedField = "time-generated-field"
+
+	// APIVersion is the Data Collector API version.
+	APIVersion = "2016-04-01"
+	// ContentType is the only content type the Data Collector API accepts.
+	ContentType = "application/json"
+
+	resource = "/api/logs"
+	// maxLogTypeLength is the longest custom log type name accepted.
+	maxLogTypeLength = 100
+)
+
+var (
+	ErrWorkspaceIDEmpty   = errors.New("workspace ID must not be empty")
+	ErrSignerRequired     = errors.New("a signer must be provided to sign requests")
+	ErrDecodingKey        = errors.New("error decoding workspace key, must be base64 encoded")
+	ErrInvalidLogType     = errors.New("log type must be at most 100 letters, numbers or underscores")
+	ErrInvalidMethod      = errors.New("data collector requests must be POST requests")
+	ErrInvalidContentType = errors.New(
+		"data collector requests must have a content type of " + ContentType,
+	)
+)
+
+// Signer authorizes Data Collector API requests with a workspace's primary or
+// secondary key.
+type Signer struct {
+	workspaceID string
+	signer      crypto.Signer
+	now         func() time.Time
+}
+
+// NewSigner returns a Data Collector request signer for the workspace, where
+// signing is delegated to the provided signer.
+func NewSigner(workspaceID string, signer crypto.Signer) (*Signer, error) {
+	if strings.TrimSpace(workspaceID) == "" {
+		return nil, ErrWorkspaceIDEmpty
+	}
+
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	return &Signer{
+		workspaceID: workspaceID,
+		signer:      signer,
+		now:         time.Now,
+	}, nil
+}
+
+// NewSignerFromKey returns a Data Collector request signer for the workspace
+// which signs with the base64 encoded workspace key.
+func NewSignerFromKey(workspaceID string, workspaceKey string) (*Signer, error) {
+	key, err := crypto.DecodeKey(workspaceKey)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrDecodingKey
+	}
+
+	s, err := NewSigner(workspaceID, signer)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close destroys the workspace key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// URL returns the workspace's Data Collector API URL.
+func (s *Signer) URL() string {
+	return "https://" + s.workspaceID + ".ods.opinsights.azure.com" +
+		resource + "?api-version=" + APIVersion
+}
+
+// StringToSign returns the string-to-sign for a request with a body of
+// contentLength bytes, sent with the RFC 1123 formatted x-ms-date.
+func StringToSign(contentLength int64, date string) string {
+	return http.MethodPost + "\n" +
+		strconv.FormatInt(contentLength, 10) + "\n" +
+		ContentType + "\n" +
+		HeaderMSDate + ":" + date + "\n" +
+		resource
+}
+
+// Authorization returns the Authorization header value for a request with a
+// body of contentLength bytes, sent with the RFC 1123 formatted x-ms-date.
+func (s *Signer) Authorization(
+	ctx context.Context,
+	contentLength int64,
+	date string,
+) (string, error) {
+	message := []byte(StringToSign(contentLength, date))
+	signature, err := s.signer.Sign(ctx, message)
+	if err != nil {
+		return "", err
+	}
+
+	return "SharedKey " + s.workspaceID + ":" + signature, nil
+}
+
+// SignRequest authorizes the request in place, setting the Content-Type and
+// x-ms-date headers if they have not been provided, then setting the
+// Authorization header. As the string-to-sign always includes ContentType,
+// requests with any other Content-Type return ErrInvalidContentType. If the
+// request's content length is unknown, the body is read to measure it, and is
+// replaced so it can still be sent.
+func (s *Signer) SignRequest(req *http.Request) error {
+	if req.Method != http.MethodPost {
+		return ErrInvalidMethod
+	}
+
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	switch req.Header.Get(HeaderContentType) {
+	case "":
+		req.Header.Set(HeaderContentType, ContentType)
+
+	case ContentType:
+		// Already signable as is.
+
+	default:
+		return ErrInvalidContentType
+	}
+
+	if req.Header.Get(HeaderMSDate) == "" {
+		req.Header.Set(HeaderMSDate, aztime.ToRFC1123(s.now()))
+	}
+
+	contentLength, err := contentLength(req)
+	if err != nil {
+		return err
+	}
+
+	date := req.Header.Get(HeaderMSDate)
+	auth, err := s.Authorization(req.Context(), contentLength, date)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderAuthorization, auth)
+
+	return nil
+}
+
+// ValidateLogType reports whether the custom log type name is acceptable,
+// returning ErrInvalidLogType if not.
+func ValidateLogType(logType string) error {
+	if logType == "" || len(logType) > maxLogTypeLength {
+		return ErrInvalidLogType
+	}
+
+	for _, c := range logType {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !isDigit && c != '_' {
+			return ErrInvalidLogType
+		}
+	}
+
+	return nil
+}
+
+// contentLength returns the length of the request body, reading the body if
+// its length is unknown.
+func contentLength(req *http.Request) (int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 0, nil
+	}
+
+	if req.ContentLength > 0 {
+		return req.ContentLength, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return 0, err
+	}
+	_ = req.Body.Close()
+
+	req.ContentLength = int64(len(body))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return req.ContentLength, nil
+}
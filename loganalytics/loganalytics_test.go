@@ -0,0 +1,188 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package loganalytics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testWorkspaceID   = "b2d6a1c4-3f5e-4a7b-9c8d-0e1f2a3b4c5d"
+	testKey           = "c2Fzc3ktd29ya3NwYWNlLWtleS0wMTIzNDU2Nzg5YWJjZGVm"
+	testBody          = `[{"device":"sensor-001","temperature":21.5}]`
+	testAuthorization = "SharedKey " + testWorkspaceID + ":IeT2gPtpiX6EizhMkr7s26yJ8uojW+Sn36xjwBFOwN0="
+)
+
+// roundTripFunc adapts a function to a http.RoundTripper.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+
+	s, err := NewSignerFromKey(testWorkspaceID, testKey)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey() error = %v", err)
+	}
+	s.now = func() time.Time { return time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC) }
+
+	return s
+}
+
+func TestSigner_SignRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentLength int64
+		contentType   string
+		wantErr       error
+	}{
+		{
+			name:          "Should sign a request with a known content length",
+			contentLength: int64(len(testBody)),
+		},
+		{
+			name:          "Should measure the body when the content length is unknown",
+			contentLength: -1,
+		},
+		{
+			name:          "Should sign a request with the Data Collector content type",
+			contentLength: int64(len(testBody)),
+			contentType:   ContentType,
+		},
+		{
+			name:          "Should not sign a request with a content type that isn't signed",
+			contentLength: int64(len(testBody)),
+			contentType:   "application/json; charset=utf-8",
+			wantErr:       ErrInvalidContentType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSigner(t)
+			defer s.Close()
+
+			req, err := http.NewRequest(http.MethodPost, s.URL(), io.NopCloser(strings.NewReader(testBody)))
+			if err != nil {
+				t.Fatalf("http.NewRequest() error = %v", err)
+			}
+			req.ContentLength = tt.contentLength
+			if tt.contentType != "" {
+				req.Header.Set(HeaderContentType, tt.contentType)
+			}
+
+			err = s.SignRequest(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SignRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got := req.Header.Get(HeaderAuthorization); got != testAuthorization {
+				t.Errorf("SignRequest()\ngot:  = %v\nwant: %v\n", got, testAuthorization)
+			}
+
+			if got := req.Header.Get(HeaderMSDate); got != "Sun, 12 Dec 2021 10:10:10 GMT" {
+				t.Errorf("SignRequest() x-ms-date\ngot:  = %v\nwant: %v\n", got, "Sun, 12 Dec 2021 10:10:10 GMT")
+			}
+
+			if sent, _ := io.ReadAll(req.Body); string(sent) != testBody {
+				t.Errorf("SignRequest() body\ngot:  = %v\nwant: %v\n", string(sent), testBody)
+			}
+		})
+	}
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	s := newTestSigner(t)
+	defer s.Close()
+
+	var sent *http.Request
+	tr := NewTransport(s, "SensorReadings", roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	tr.TimeGeneratedField = "readAt"
+
+	req, err := http.NewRequest(http.MethodPost, s.URL(), strings.NewReader(testBody))
+	if err != nil {
+		t.Fatalf("http.NewRequest() error = %v", err)
+	}
+
+	if _, err = tr.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+
+	if req.Header.Get(HeaderAuthorization) != "" {
+		t.Errorf("RoundTrip() modified the original request")
+	}
+
+	want := map[string]string{
+		HeaderAuthorization:      testAuthorization,
+		HeaderContentType:        ContentType,
+		HeaderLogType:            "SensorReadings",
+		HeaderTimeGeneratedField: "readAt",
+	}
+	for header, value := range want {
+		if got := sent.Header.Get(header); got != value {
+			t.Errorf("RoundTrip() %s\ngot:  = %v\nwant: %v\n", header, got, value)
+		}
+	}
+}
+
+func TestValidateLogType(t *testing.T) {
+	tests := []struct {
+		name    string
+		logType string
+		wantErr error
+	}{
+		{
+			name:    "Should accept letters, numbers and underscores",
+			logType: "Sensor_Readings_2",
+		},
+		{
+			name:    "Should error on an empty log type",
+			logType: "",
+			wantErr: ErrInvalidLogType,
+		},
+		{
+			name:    "Should error on a hyphen",
+			logType: "sensor-readings",
+			wantErr: ErrInvalidLogType,
+		},
+		{
+			name:    "Should error on a log type over 100 characters",
+			logType: strings.Repeat("a", 101),
+			wantErr: ErrInvalidLogType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateLogType(tt.logType); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateLogType()\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package loganalytics
+
+import (
+	// Standard Library Imports
+	"net/http"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/transport"
+)
+
+// Transport is a http.RoundTripper which sets the Log-Type and
+// time-generated-field headers and authorizes each request with Shared Key
+// before passing it on to the base round tripper.
+type Transport struct {
+	// Signer authorizes requests.
+	Signer *Signer
+	// LogType is the custom log type records are sent to, used if the request
+	// doesn't set the Log-Type header.
+	LogType string
+	// TimeGeneratedField optionally names the record field holding the
+	// record's TimeGenerated timestamp, used if the request doesn't set the
+	// time-generated-field header.
+	TimeGeneratedField string
+	// Base is the underlying round tripper. Defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// NewTransport returns a Data Collector authorizing round tripper which sends
+// records to the custom log type. If base is nil, http.DefaultTransport is
+// used.
+func NewTransport(signer *Signer, logType string, base http.RoundTripper) *Transport {
+	return &Transport{
+		Signer:  signer,
+		LogType: logType,
+		Base:    base,
+	}
+}
+
+// RoundTrip implements http.RoundTripper, setting the log type headers on a
+// clone of the request before authorizing it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return transport.New(transport.RequestSignerFunc(t.sign), t.Base).RoundTrip(req)
+}
+
+// sign sets the log type headers, then authorizes the request.
+func (t *Transport) sign(req *http.Request) error {
+	if req.Header.Get(HeaderLogType) == "" {
+		req.Header.Set(HeaderLogType, t.LogType)
+	}
+
+	if err := ValidateLogType(req.Header.Get(HeaderLogType)); err != nil {
+		return err
+	}
+
+	if t.TimeGeneratedField != "" && req.Header.Get(HeaderTimeGeneratedField) == "" {
+		req.Header.Set(HeaderTimeGeneratedField, t.TimeGeneratedField)
+	}
+
+	return t.Signer.SignRequest(req)
+}
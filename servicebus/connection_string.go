@@ -0,0 +1,210 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package servicebus
+
+import (
+	// Standard Library Imports
+	"errors"
+	"net/url"
+	"strings"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+// Connection string keys.
+// Refer: https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-authentication-and-authorization
+const (
+	ConnectionStringEndpoint              = "Endpoint"
+	ConnectionStringSharedAccessKeyName   = "SharedAccessKeyName"
+	ConnectionStringSharedAccessKey       = "SharedAccessKey"
+	ConnectionStringEntityPath            = "EntityPath"
+	ConnectionStringSharedAccessSignature = "SharedAccessSignature"
+)
+
+const (
+	// publishersPath is the path segment under which Event Hubs publisher
+	// scoped URIs are nested.
+	publishersPath = "publishers"
+)
+
+var (
+	ErrInvalidEndpoint   = errors.New("endpoint must be an absolute URI, for example, sb://<namespace>.servicebus.windows.net/")
+	ErrKeyAndSAS         = errors.New("a shared access key and a shared access signature must not both be provided")
+	ErrPublisherEmpty    = errors.New("publisher must not be empty")
+	ErrEntityPathMissing = errors.New("an entity path is required to build an entity scoped URI")
+)
+
+// ConnectionString holds a parsed Service Bus, Event Hubs, Relay or
+// Notification Hubs connection string.
+type ConnectionString struct {
+	// Endpoint is the namespace endpoint, for example,
+	// sb://<namespace>.servicebus.windows.net/.
+	Endpoint            *url.URL
+	SharedAccessKeyName string
+	// SharedAccessKey is nil if the connection string uses a shared access
+	// signature instead of a shared access key.
+	SharedAccessKey       *crypto.Key
+	EntityPath            string
+	SharedAccessSignature *Token
+}
+
+// ParseConnectionString parses a Service Bus family connection string,
+// supporting either a shared access key or a shared access signature.
+//
+// Errors are returned as a *connstr.Error, naming the malformed part of the
+// connection string.
+func ParseConnectionString(connectionString string) (*ConnectionString, error) {
+	values, err := connstr.Parse(
+		connectionString,
+		ConnectionStringEndpoint,
+		ConnectionStringSharedAccessKeyName,
+		ConnectionStringSharedAccessKey,
+		ConnectionStringEntityPath,
+		ConnectionStringSharedAccessSignature,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = values.Require(ConnectionStringEndpoint); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(values.Get(ConnectionStringEndpoint))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, &connstr.Error{Key: ConnectionStringEndpoint, Err: ErrInvalidEndpoint}
+	}
+
+	cs := &ConnectionString{
+		Endpoint:            endpoint,
+		SharedAccessKeyName: values.Get(ConnectionStringSharedAccessKeyName),
+		EntityPath:          strings.Trim(values.Get(ConnectionStringEntityPath), "/"),
+	}
+
+	hasKey := values.Has(ConnectionStringSharedAccessKey)
+	hasSAS := values.Has(ConnectionStringSharedAccessSignature)
+	switch {
+	case hasKey && hasSAS:
+		return nil, &connstr.Error{Key: ConnectionStringSharedAccessSignature, Err: ErrKeyAndSAS}
+
+	case hasKey:
+		if err = values.Require(ConnectionStringSharedAccessKeyName, ConnectionStringSharedAccessKey); err != nil {
+			return nil, err
+		}
+
+		if cs.SharedAccessKey, err = crypto.NewKey([]byte(values.Get(ConnectionStringSharedAccessKey))); err != nil {
+			return nil, &connstr.Error{Key: ConnectionStringSharedAccessKey, Err: ErrKeyEmpty}
+		}
+
+	case hasSAS:
+		if cs.SharedAccessSignature, err = ParseToken(values.Get(ConnectionStringSharedAccessSignature)); err != nil {
+			return nil, &connstr.Error{Key: ConnectionStringSharedAccessSignature, Err: err}
+		}
+
+	default:
+		return nil, &connstr.Error{Key: ConnectionStringSharedAccessKey, Err: connstr.ErrMissingKey}
+	}
+
+	return cs, nil
+}
+
+// NamespaceURI returns the namespace's resource URI, using the https scheme
+// in place of the sb scheme, as per the documented token audience.
+func (c *ConnectionString) NamespaceURI() string {
+	u := url.URL{
+		Scheme: "https",
+		Host:   c.Endpoint.Host,
+		Path:   "/",
+	}
+
+	return u.String()
+}
+
+// ResourceURI returns the resource URI of the connection string's entity, or
+// of the namespace if no entity path is set.
+func (c *ConnectionString) ResourceURI() string {
+	if c.EntityPath == "" {
+		return c.NamespaceURI()
+	}
+
+	return c.NamespaceURI() + c.EntityPath
+}
+
+// PublisherURI returns the Event Hubs publisher scoped resource URI for the
+// connection string's event hub, which limits a token to sending events as
+// the given publisher.
+func (c *ConnectionString) PublisherURI(publisher string) (string, error) {
+	if c.EntityPath == "" {
+		return "", ErrEntityPathMissing
+	}
+
+	return PublisherURI(c.ResourceURI(), publisher)
+}
+
+// Signer returns a token signer for the connection string's shared access
+// policy. The signer shares the connection string's key, so closing the
+// signer destroys the key.
+func (c *ConnectionString) Signer() (*Signer, error) {
+	if c.SharedAccessKey == nil {
+		return nil, ErrKeyEmpty
+	}
+
+	signer, err := crypto.NewKeySigner(c.SharedAccessKey)
+	if err != nil {
+		return nil, ErrKeyEmpty
+	}
+
+	return NewSigner(c.SharedAccessKeyName, signer)
+}
+
+// NewSignerFromConnectionString returns a token signer for the shared access
+// policy in the connection string, along with the parsed connection string
+// which provides the resource URIs to sign.
+func NewSignerFromConnectionString(connectionString string) (*Signer, *ConnectionString, error) {
+	cs, err := ParseConnectionString(connectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cs.SharedAccessKey == nil {
+		return nil, nil, &connstr.Error{Key: ConnectionStringSharedAccessKey, Err: connstr.ErrMissingKey}
+	}
+
+	s, err := newSignerWithKey(cs.SharedAccessKeyName, cs.SharedAccessKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, cs, nil
+}
+
+// PublisherURI returns the Event Hubs publisher scoped resource URI for the
+// given event hub URI, for example,
+// https://<namespace>.servicebus.windows.net/<event-hub>/publishers/<publisher>.
+func PublisherURI(eventHubURI string, publisher string) (string, error) {
+	if strings.TrimSpace(eventHubURI) == "" {
+		return "", ErrResourceURIEmpty
+	}
+
+	if strings.TrimSpace(publisher) == "" {
+		return "", ErrPublisherEmpty
+	}
+
+	return strings.TrimSuffix(eventHubURI, "/") + "/" + publishersPath + "/" + url.PathEscape(publisher), nil
+}
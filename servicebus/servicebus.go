@@ -0,0 +1,163 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package servicebus provides generation, parsing and verification of the
+// shared access signature tokens used by Service Bus, Event Hubs, Relay and
+// Notification Hubs.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-sas
+package servicebus
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/internal/sastoken"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	// TokenPrefix prefixes every Service Bus SAS token.
+	TokenPrefix = sastoken.Prefix
+)
+
+var (
+	ErrKeyNameEmpty      = errors.New("shared access key name must not be empty")
+	ErrKeyEmpty          = errors.New("shared access key must not be empty")
+	ErrSignerRequired    = sastoken.ErrSignerRequired
+	ErrResourceURIEmpty  = sastoken.ErrResourceURIEmpty
+	ErrExpiryRequired    = sastoken.ErrExpiryRequired
+	ErrInvalidToken      = sastoken.ErrInvalidToken
+	ErrKeyNameMismatch   = sastoken.ErrKeyNameMismatch
+	ErrSignatureMismatch = sastoken.ErrSignatureMismatch
+	ErrTokenExpired      = sastoken.ErrTokenExpired
+)
+
+// format encodes token values with url.QueryEscape and, unlike storage
+// account keys, uses keys as is, rather than base64 decoding them.
+var format = sastoken.Format{
+	Escape: url.QueryEscape,
+	DecodeKey: func(key string) (*crypto.Key, error) {
+		return crypto.NewKey([]byte(key))
+	},
+	ErrKey:          ErrKeyEmpty,
+	ErrKeyNameEmpty: ErrKeyNameEmpty,
+}
+
+// Token is a Service Bus shared access signature token, holding the URI of
+// the Resource it grants access to, the KeyName of the shared access policy it
+// was signed with, its Expiry and the base64 encoded Signature.
+type Token sastoken.Token
+
+// ParseToken parses a Service Bus shared access signature token, with or
+// without the "SharedAccessSignature " prefix.
+func ParseToken(token string) (*Token, error) {
+	t, err := sastoken.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*Token)(t), nil
+}
+
+// String implements Stringer, returning the token in the form used in the
+// Authorization header and AMQP put-token messages.
+func (t *Token) String() string {
+	return (*sastoken.Token)(t).String(format)
+}
+
+// StringToSign returns the message the token's signature is computed over.
+func (t *Token) StringToSign() string {
+	return (*sastoken.Token)(t).StringToSign(format)
+}
+
+// Expired reports whether the token has expired at the given time.
+func (t *Token) Expired(now time.Time) bool {
+	return (*sastoken.Token)(t).Expired(now)
+}
+
+// Signer generates and verifies Service Bus shared access signature tokens
+// for a shared access policy.
+type Signer struct {
+	sas *sastoken.Signer
+}
+
+// NewSigner returns a token signer for the named shared access policy, where
+// signing is delegated to the provided signer.
+//
+// Unlike storage account keys, Service Bus keys are used as is, rather than
+// being base64 decoded, so the signer must sign with the key's raw bytes.
+func NewSigner(keyName string, signer crypto.Signer) (*Signer, error) {
+	return newSigner(sastoken.NewSigner(format, keyName, signer))
+}
+
+// NewSignerFromKey returns a token signer for the named shared access policy
+// which signs with the policy's primary or secondary key.
+func NewSignerFromKey(keyName string, key string) (*Signer, error) {
+	return newSigner(sastoken.NewSignerFromKey(format, keyName, key))
+}
+
+// newSignerWithKey returns a token signer which takes ownership of the key,
+// destroying it on error.
+func newSignerWithKey(keyName string, key *crypto.Key) (*Signer, error) {
+	return newSigner(sastoken.NewSignerWithKey(format, keyName, key))
+}
+
+func newSigner(sas *sastoken.Signer, err error) (*Signer, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{sas: sas}, nil
+}
+
+// KeyName returns the name of the shared access policy tokens are signed
+// with.
+func (s *Signer) KeyName() string {
+	return s.sas.KeyName()
+}
+
+// Close destroys the shared access policy key, if held in memory.
+func (s *Signer) Close() error {
+	return s.sas.Close()
+}
+
+// Token returns a token granting access to the resource URI until expiry.
+func (s *Signer) Token(resourceURI string, expiry time.Time) (*Token, error) {
+	return s.TokenContext(context.Background(), resourceURI, expiry)
+}
+
+// TokenContext returns a token granting access to the resource URI until
+// expiry, passing the context through to the signer.
+func (s *Signer) TokenContext(ctx context.Context, resourceURI string, expiry time.Time) (*Token, error) {
+	t, err := s.sas.Token(ctx, resourceURI, expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*Token)(t), nil
+}
+
+// Verify reports whether the token was signed by the signer, or by any key of
+// a crypto.Verifier such as a crypto.Keyring, and has not expired, returning
+// ErrKeyNameMismatch, ErrSignatureMismatch or ErrTokenExpired if not.
+func (s *Signer) Verify(ctx context.Context, token *Token) error {
+	return s.sas.Verify(ctx, (*sastoken.Token)(token))
+}
@@ -0,0 +1,368 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package servicebus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	testKeyName = "RootManageSharedAccessKey"
+	// testKey is deliberately base64 shaped, as Service Bus keys must be used
+	// as is, rather than decoded.
+	testKey = "c2Fzc3kta2V5"
+)
+
+var testExpiry = time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC)
+
+func TestSigner_Token(t *testing.T) {
+	tests := []struct {
+		name        string
+		resourceURI string
+		want        string
+	}{
+		{
+			name:        "Should sign an entity URI",
+			resourceURI: "https://sassy.servicebus.windows.net/orders",
+			want: "SharedAccessSignature sr=https%3A%2F%2Fsassy.servicebus.windows.net%2Forders" +
+				"&sig=%2FAd2l7YZm8AgOXlFCH%2FlR8Z3Q8WzobGbV%2Fw%2Bs1nzUwk%3D" +
+				"&se=1639303810&skn=RootManageSharedAccessKey",
+		},
+		{
+			name:        "Should sign an Event Hubs publisher URI",
+			resourceURI: "https://sassy.servicebus.windows.net/telemetry/publishers/device-1",
+			want: "SharedAccessSignature sr=https%3A%2F%2Fsassy.servicebus.windows.net%2Ftelemetry%2Fpublishers%2Fdevice-1" +
+				"&sig=xJhgO2y4wdrGLG71w0XVN8nn%2FYfyalYHA%2BQy7srpZwQ%3D" +
+				"&se=1639303810&skn=RootManageSharedAccessKey",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSignerFromKey(testKeyName, testKey)
+			if err != nil {
+				t.Fatalf("NewSignerFromKey() error = %v", err)
+			}
+			defer s.Close()
+
+			token, err := s.Token(tt.resourceURI, testExpiry.Add(500*time.Millisecond))
+			if err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+
+			if got := token.String(); got != tt.want {
+				t.Errorf("Token()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    Token
+		wantErr error
+	}{
+		{
+			name: "Should parse a token",
+			token: "SharedAccessSignature sr=https%3A%2F%2Fsassy.servicebus.windows.net%2Forders" +
+				"&sig=%2FAd2l7YZm8AgOXlFCH%2FlR8Z3Q8WzobGbV%2Fw%2Bs1nzUwk%3D" +
+				"&se=1639303810&skn=RootManageSharedAccessKey",
+			want: Token{
+				Resource:  "https://sassy.servicebus.windows.net/orders",
+				KeyName:   testKeyName,
+				Expiry:    testExpiry,
+				Signature: "/Ad2l7YZm8AgOXlFCH/lR8Z3Q8WzobGbV/w+s1nzUwk=",
+			},
+		},
+		{
+			name:  "Should parse a token without the prefix in any parameter order",
+			token: "se=1639303810&sig=abc+def%3D&sr=sb%3A%2F%2Fsassy.servicebus.windows.net%2F",
+			want: Token{
+				Resource:  "sb://sassy.servicebus.windows.net/",
+				Expiry:    testExpiry,
+				Signature: "abc+def=",
+			},
+		},
+		{
+			name:    "Should error on a missing signature",
+			token:   "SharedAccessSignature sr=a&se=1639303810&skn=b",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Should error on an invalid expiry",
+			token:   "SharedAccessSignature sr=a&sig=b&se=2021-12-12&skn=c",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Should error on duplicate parameters",
+			token:   "SharedAccessSignature sr=a&sr=a&sig=b&se=1639303810",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "Should error on unknown parameters",
+			token:   "SharedAccessSignature sr=a&sig=b&se=1639303810&sv=2020-10-02",
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.Resource != tt.want.Resource ||
+				got.KeyName != tt.want.KeyName ||
+				!got.Expiry.Equal(tt.want.Expiry) ||
+				got.Signature != tt.want.Signature {
+				t.Errorf("ParseToken()\ngot:  = %+v\nwant: %+v\n", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSigner_Verify(t *testing.T) {
+	token := "SharedAccessSignature sr=https%3A%2F%2Fsassy.servicebus.windows.net%2Forders" +
+		"&sig=%2FAd2l7YZm8AgOXlFCH%2FlR8Z3Q8WzobGbV%2Fw%2Bs1nzUwk%3D" +
+		"&se=1639303810&skn=RootManageSharedAccessKey"
+
+	tests := []struct {
+		name    string
+		keyName string
+		key     string
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name:    "Should verify a valid token",
+			keyName: testKeyName,
+			key:     testKey,
+			token:   token,
+			now:     testExpiry.Add(-time.Minute),
+		},
+		{
+			name:    "Should error on an expired token",
+			keyName: testKeyName,
+			key:     testKey,
+			token:   token,
+			now:     testExpiry,
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "Should error if signed with a different key",
+			keyName: testKeyName,
+			key:     "b3RoZXIta2V5",
+			token:   token,
+			now:     testExpiry.Add(-time.Minute),
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name:    "Should error if signed with a different policy",
+			keyName: "send",
+			key:     testKey,
+			token:   token,
+			now:     testExpiry.Add(-time.Minute),
+			wantErr: ErrKeyNameMismatch,
+		},
+		{
+			name:    "Should error if the expiry has been tampered with",
+			keyName: testKeyName,
+			key:     testKey,
+			token:   token[:len(token)-len("1639303810&skn=RootManageSharedAccessKey")] + "1639303811&skn=RootManageSharedAccessKey",
+			now:     testExpiry.Add(-time.Minute),
+			wantErr: ErrSignatureMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSignerFromKey(tt.keyName, tt.key)
+			if err != nil {
+				t.Fatalf("NewSignerFromKey() error = %v", err)
+			}
+			defer s.Close()
+			s.sas.Now = func() time.Time { return tt.now }
+
+			token, err := ParseToken(tt.token)
+			if err != nil {
+				t.Fatalf("ParseToken() error = %v", err)
+			}
+
+			if err := s.Verify(context.Background(), token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify()\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSigner_Verify_Keyring(t *testing.T) {
+	token, err := ParseToken("SharedAccessSignature sr=https%3A%2F%2Fsassy.servicebus.windows.net%2Forders" +
+		"&sig=%2FAd2l7YZm8AgOXlFCH%2FlR8Z3Q8WzobGbV%2Fw%2Bs1nzUwk%3D" +
+		"&se=1639303810&skn=RootManageSharedAccessKey")
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr error
+	}{
+		{
+			name: "Should verify a token signed with the primary key",
+			keys: []string{testKey, "b3RoZXIta2V5"},
+		},
+		{
+			name: "Should verify a token signed with the secondary key",
+			keys: []string{"b3RoZXIta2V5", testKey},
+		},
+		{
+			name:    "Should error if signed with neither key",
+			keys:    []string{"b3RoZXIta2V5", "dGhpcmQta2V5"},
+			wantErr: ErrSignatureMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring := crypto.NewKeyring()
+			for i, value := range tt.keys {
+				key, err := crypto.NewKey([]byte(value))
+				if err != nil {
+					t.Fatalf("NewKey() error = %v", err)
+				}
+				if err = keyring.Add(fmt.Sprintf("key%d", i+1), key); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+			}
+
+			s, err := NewSigner(testKeyName, keyring)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			defer s.Close()
+			s.sas.Now = func() time.Time { return testExpiry.Add(-time.Minute) }
+
+			if err := s.Verify(context.Background(), token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify()\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		wantResourceURI  string
+		wantKeyName      string
+		wantErrKey       string
+		wantErr          error
+	}{
+		{
+			name:             "Should parse a namespace connection string",
+			connectionString: "Endpoint=sb://sassy.servicebus.windows.net/;SharedAccessKeyName=RootManageSharedAccessKey;SharedAccessKey=c2Fzc3kta2V5",
+			wantResourceURI:  "https://sassy.servicebus.windows.net/",
+			wantKeyName:      testKeyName,
+		},
+		{
+			name:             "Should parse an entity connection string",
+			connectionString: "Endpoint=sb://sassy.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=c2Fzc3kta2V5=;EntityPath=telemetry",
+			wantResourceURI:  "https://sassy.servicebus.windows.net/telemetry",
+			wantKeyName:      "send",
+		},
+		{
+			name:             "Should ignore unknown keys added by the Azure portal",
+			connectionString: "Endpoint=sb://sassy.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=c2Fzc3kta2V5=;EntityPath=telemetry;TransportType=Amqp",
+			wantResourceURI:  "https://sassy.servicebus.windows.net/telemetry",
+			wantKeyName:      "send",
+		},
+		{
+			name:             "Should parse a shared access signature connection string",
+			connectionString: "Endpoint=sb://sassy.servicebus.windows.net/;SharedAccessSignature=SharedAccessSignature sr=a&sig=b&se=1639303810&skn=c",
+			wantResourceURI:  "https://sassy.servicebus.windows.net/",
+		},
+		{
+			name:             "Should error on a missing endpoint",
+			connectionString: "SharedAccessKeyName=send;SharedAccessKey=c2Fzc3kta2V5",
+			wantErrKey:       ConnectionStringEndpoint,
+			wantErr:          connstr.ErrMissingKey,
+		},
+		{
+			name:             "Should error on a relative endpoint",
+			connectionString: "Endpoint=sassy;SharedAccessKeyName=send;SharedAccessKey=c2Fzc3kta2V5",
+			wantErrKey:       ConnectionStringEndpoint,
+			wantErr:          ErrInvalidEndpoint,
+		},
+		{
+			name:             "Should error on a key without a key name",
+			connectionString: "Endpoint=sb://sassy.servicebus.windows.net/;SharedAccessKey=c2Fzc3kta2V5",
+			wantErrKey:       ConnectionStringSharedAccessKeyName,
+			wantErr:          connstr.ErrMissingKey,
+		},
+		{
+			name:             "Should error on an invalid shared access signature",
+			connectionString: "Endpoint=sb://sassy.servicebus.windows.net/;SharedAccessSignature=sv=2020-10-02",
+			wantErrKey:       ConnectionStringSharedAccessSignature,
+			wantErr:          ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionString(tt.connectionString)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseConnectionString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var csErr *connstr.Error
+				if !errors.As(err, &csErr) || csErr.Key != tt.wantErrKey {
+					t.Errorf("ParseConnectionString()\ngot:  = %v\nwant: key %v\n", err, tt.wantErrKey)
+				}
+				return
+			}
+
+			if uri := got.ResourceURI(); uri != tt.wantResourceURI {
+				t.Errorf("ResourceURI()\ngot:  = %v\nwant: %v\n", uri, tt.wantResourceURI)
+			}
+			if got.SharedAccessKeyName != tt.wantKeyName {
+				t.Errorf("SharedAccessKeyName\ngot:  = %v\nwant: %v\n", got.SharedAccessKeyName, tt.wantKeyName)
+			}
+		})
+	}
+}
+
+func TestPublisherURI(t *testing.T) {
+	got, err := PublisherURI("https://sassy.servicebus.windows.net/telemetry/", "device 1")
+	if err != nil {
+		t.Fatalf("PublisherURI() error = %v", err)
+	}
+
+	want := "https://sassy.servicebus.windows.net/telemetry/publishers/device%201"
+	if got != want {
+		t.Errorf("PublisherURI()\ngot:  = %v\nwant: %v\n", got, want)
+	}
+}
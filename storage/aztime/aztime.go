@@ -19,7 +19,9 @@ package aztime
 import (
 	// Standard Library Imports
 	"errors"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -29,42 +31,157 @@ const (
 	ParamKeySignedExpiry = "se"
 )
 
+// MaxPrecision is the maximum number of fractional second digits Azure
+// accepts in a timestamp, for example, snapshot and delegation key times.
+const MaxPrecision = 7
+
+// EventGridFormat is the .NET en-US general date/time format Event Grid SAS
+// token expiries are formatted with, in UTC.
+const EventGridFormat = "1/2/2006 3:04:05 PM"
+
 var (
-	ErrDateTimeEmpty = errors.New("datetime provided to parse is empty")
+	ErrDateTimeEmpty          = errors.New("datetime provided to parse is empty")
+	ErrInvalidDateTimeFormat  = errors.New("datetime provided is not a valid ISO 8601 formatted date string")
+	ErrDateTimeNonExistent    = errors.New("datetime provided does not exist in the timezone, it falls in a daylight saving gap")
+	ErrDateTimeAmbiguous      = errors.New("datetime provided is ambiguous in the timezone, it falls in a daylight saving overlap")
+	ErrUnknownTimeZone        = errors.New("unknown timezone, must be an IANA timezone name")
+	ErrInvalidUnixTimestamp   = errors.New("timestamp provided is not a valid number of seconds since the Unix epoch")
+	ErrInvalidEventGridFormat = errors.New("datetime provided is not formatted as M/d/yyyy h:mm:ss tt")
 )
 
-// ParseISO8601DateTime provides a much more CLI user-friendly time parser which
-// attempts to parse from least-to-greatest precision, failing if it .
+// ParseISO8601DateTime provides a much more CLI user-friendly time parser
+// which parses the practical ISO 8601 profile, interpreting any date or time
+// without a timezone designator in the local timezone.
+//
+// Supported date formats, in both extended and basic format:
+// - YYYY, YYYY-MM, YYYY-MM-DD, YYYYMMDD (calendar dates)
+// - YYYY-DDD, YYYYDDD (ordinal dates)
+// - YYYY-Www, YYYY-Www-D, YYYYWww, YYYYWwwD (week dates)
+//
+// Supported time formats, in both extended and basic format:
+// - hh, hh:mm, hh:mm:ss, hhmm, hhmmss
+// - hh:mm:ss.sss, hhmmss.sss (any number of fractional digits, '.' or ',')
+// - 24:00 (midnight at the end of the given day)
+//
+// Supported timezone designators, which can follow either a date or time:
+// - Z, ±hh, ±hh:mm, ±hhmm
+//
+// Date times without a timezone designator that fall in a daylight saving gap
+// or overlap are reported as ErrDateTimeNonExistent or ErrDateTimeAmbiguous.
+// Dates without a time are the first instant of that day, even if daylight
+// saving skips midnight.
 func ParseISO8601DateTime(dateTime string) (t time.Time, err error) {
-	dateTime = strings.TrimSpace(dateTime)
-	if dateTime == "" {
-		return time.Time{}, ErrDateTimeEmpty
+	return parseISO8601(dateTime, time.Local)
+}
+
+// ParseISO8601DateTimeInLocation parses an ISO 8601 date time in the same way
+// as ParseISO8601DateTime, but interprets any date or time without a timezone
+// designator in the given location. A nil location uses the local timezone.
+func ParseISO8601DateTimeInLocation(dateTime string, loc *time.Location) (t time.Time, err error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return parseISO8601(dateTime, loc)
+}
+
+// ParseISO8601DateTimeInZone parses an ISO 8601 date time in the same way as
+// ParseISO8601DateTime, but interprets any date or time without a timezone
+// designator in the named IANA timezone, for example, "Pacific/Auckland".
+func ParseISO8601DateTimeInZone(dateTime string, zone string) (t time.Time, err error) {
+	loc, err := LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, err
 	}
 
-	inputFormats := []string{
-		"2006-01-02",             // YYYY-MM-DD
-		"2006-01-02Z07:00",       // YYYY-MM-DD<TZDSuffix>
-		"2006-01-02T15:04",       // YYYY-MM-DDThh:mm
-		"2006-01-02T15:04Z07:00", // YYYY-MM-DDThh:mm<TZDSuffix>
-		"2006-01-02T15:04:05",    // YYYY-MM-DDThh:mm:ss
-		time.RFC3339,             // YYYY-MM-DDThh:mm:ss<TZDSuffix>
+	return parseISO8601(dateTime, loc)
+}
+
+// LoadLocation returns the location for the named IANA timezone. In addition
+// to IANA names, "UTC" and "Local" are supported.
+func LoadLocation(zone string) (*time.Location, error) {
+	zone = strings.TrimSpace(zone)
+	if zone == "" {
+		return nil, ErrUnknownTimeZone
 	}
 
-	for _, format := range inputFormats {
-		// Parse the incoming time in local timezone
-		if t, err = time.ParseInLocation(format, dateTime, time.Local); err == nil {
-			return t, nil
-		}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, ErrUnknownTimeZone
 	}
 
-	return t, err
+	return loc, nil
 }
 
+// ToString formats a timestamp in UTC, to second level precision, as required
+// by the storage signed start and signed expiry fields.
 func ToString(t time.Time) string {
 	// Timestamps sent through MUST be in UTC.
 	return t.UTC().Format(time.RFC3339)
 }
 
+// ToStringWithPrecision formats a timestamp in UTC with the given number of
+// fractional second digits. Precision is clamped between 0 and MaxPrecision.
+func ToStringWithPrecision(t time.Time, precision int) string {
+	if precision <= 0 {
+		return ToString(t)
+	}
+	if precision > MaxPrecision {
+		precision = MaxPrecision
+	}
+
+	layout := "2006-01-02T15:04:05." + strings.Repeat("0", precision) + "Z07:00"
+	return t.UTC().Format(layout)
+}
+
+// ToRFC1123 formats a timestamp as an RFC 1123 date in GMT, as required by the
+// Date and x-ms-date headers, for example, "Sun, 12 Dec 2021 10:10:10 GMT".
+func ToRFC1123(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
+
+// ToUnix formats a timestamp as the number of seconds since the Unix epoch, as
+// used by Service Bus and IoT Hub token expiries.
+func ToUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// ParseUnix parses a timestamp formatted as the number of seconds since the
+// Unix epoch.
+func ParseUnix(epoch string) (time.Time, error) {
+	epoch = strings.TrimSpace(epoch)
+	if epoch == "" {
+		return time.Time{}, ErrDateTimeEmpty
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidUnixTimestamp
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// ToEventGrid formats a timestamp in UTC using EventGridFormat.
+func ToEventGrid(t time.Time) string {
+	return t.UTC().Format(EventGridFormat)
+}
+
+// ParseEventGrid parses a UTC timestamp formatted using EventGridFormat.
+func ParseEventGrid(datetime string) (time.Time, error) {
+	datetime = strings.TrimSpace(datetime)
+	if datetime == "" {
+		return time.Time{}, ErrDateTimeEmpty
+	}
+
+	t, err := time.Parse(EventGridFormat, datetime)
+	if err != nil {
+		return time.Time{}, ErrInvalidEventGridFormat
+	}
+
+	return t, nil
+}
+
 func GetParam(paramKey string, t time.Time) (timeParam string) {
 	if !t.IsZero() {
 		params := &url.Values{}
@@ -0,0 +1,406 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aztime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseISO8601DateTime(t *testing.T) {
+	nzdt := time.FixedZone("", 13*60*60)
+	est := time.FixedZone("", -5*60*60)
+
+	type args struct {
+		dateTime string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    time.Time
+		wantErr error
+	}{
+		// Errors
+		{
+			name:    "Should error on an empty datetime",
+			args:    args{dateTime: "   "},
+			wantErr: ErrDateTimeEmpty,
+		},
+		{
+			name:    "Should error on an invalid month",
+			args:    args{dateTime: "2021-13-12"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+		{
+			name:    "Should error on an invalid day",
+			args:    args{dateTime: "2021-02-29"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+		{
+			name:    "Should error on an invalid hour",
+			args:    args{dateTime: "2021-12-12T25:00Z"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+		{
+			name:    "Should error on 24:00 with minutes",
+			args:    args{dateTime: "2021-12-12T24:01Z"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+		{
+			name:    "Should error on an out of range ordinal date",
+			args:    args{dateTime: "2021-366"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+		{
+			name:    "Should error on week 53 in a year with 52 weeks",
+			args:    args{dateTime: "2021-W53"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+		{
+			name:    "Should error on trailing characters",
+			args:    args{dateTime: "2021-12-12T10:10:10Zulu"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+		{
+			name:    "Should error on an empty fraction",
+			args:    args{dateTime: "2021-12-12T10:10:10.Z"},
+			wantErr: ErrInvalidDateTimeFormat,
+		},
+
+		// Calendar dates
+		{
+			name: "Should parse an extended date in UTC",
+			args: args{dateTime: "2021-12-12Z"},
+			want: time.Date(2021, 12, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a basic date with an offset",
+			args: args{dateTime: "20211212+13:00"},
+			want: time.Date(2021, 12, 12, 0, 0, 0, 0, nzdt),
+		},
+		{
+			name: "Should parse an extended date with a negative offset",
+			args: args{dateTime: "2021-12-12-05:00"},
+			want: time.Date(2021, 12, 12, 0, 0, 0, 0, est),
+		},
+
+		// Ordinal dates
+		{
+			name: "Should parse an extended ordinal date",
+			args: args{dateTime: "2020-366Z"},
+			want: time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a basic ordinal date",
+			args: args{dateTime: "2021346T10:10Z"},
+			want: time.Date(2021, 12, 12, 10, 10, 0, 0, time.UTC),
+		},
+
+		// Week dates
+		{
+			name: "Should parse an extended week date",
+			args: args{dateTime: "2021-W49-7Z"},
+			want: time.Date(2021, 12, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a basic week date",
+			args: args{dateTime: "2021W497T101010Z"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC),
+		},
+		{
+			name: "Should parse a week date that starts in the previous year",
+			args: args{dateTime: "2021-W01Z"},
+			want: time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a week date that ends in the next year",
+			args: args{dateTime: "2020-W53-5Z"},
+			want: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+
+		// Times
+		{
+			name: "Should parse RFC 3339",
+			args: args{dateTime: "2021-12-12T10:10:10Z"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC),
+		},
+		{
+			name: "Should parse the basic format",
+			args: args{dateTime: "20211212T101010Z"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC),
+		},
+		{
+			name: "Should parse an offset without a colon",
+			args: args{dateTime: "2021-12-12T10:10:10+1300"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 0, nzdt),
+		},
+		{
+			name: "Should parse an hour only offset",
+			args: args{dateTime: "2021-12-12T10:10-05"},
+			want: time.Date(2021, 12, 12, 10, 10, 0, 0, est),
+		},
+		{
+			name: "Should parse fractional seconds",
+			args: args{dateTime: "2021-12-12T10:10:10.1234567Z"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 123456700, time.UTC),
+		},
+		{
+			name: "Should parse fractional seconds with a comma",
+			args: args{dateTime: "20211212T101010,5Z"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 500000000, time.UTC),
+		},
+		{
+			name: "Should truncate fractional seconds beyond nanoseconds",
+			args: args{dateTime: "2021-12-12T10:10:10.1234567899Z"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 123456789, time.UTC),
+		},
+		{
+			name: "Should parse 24:00 as the end of the day",
+			args: args{dateTime: "2021-12-12T24:00Z"},
+			want: time.Date(2021, 12, 13, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a lowercase designator",
+			args: args{dateTime: "2021-12-12t10:10z"},
+			want: time.Date(2021, 12, 12, 10, 10, 0, 0, time.UTC),
+		},
+
+		// Local time
+		{
+			name: "Should parse a date without a timezone in local time",
+			args: args{dateTime: "2021-12-12"},
+			want: time.Date(2021, 12, 12, 0, 0, 0, 0, time.Local),
+		},
+		{
+			name: "Should parse a time without a timezone in local time",
+			args: args{dateTime: "2021-12-12T10:10:10"},
+			want: time.Date(2021, 12, 12, 10, 10, 10, 0, time.Local),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseISO8601DateTime(tt.args.dateTime)
+			if err != tt.wantErr {
+				t.Errorf("ParseISO8601DateTime() error\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseISO8601DateTime() time\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToStringWithPrecision(t *testing.T) {
+	input := time.Date(2021, 12, 12, 10, 10, 10, 123456789, time.FixedZone("", 13*60*60))
+
+	type args struct {
+		t         time.Time
+		precision int
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Should format to seconds with no precision",
+			args: args{t: input, precision: 0},
+			want: "2021-12-11T21:10:10Z",
+		},
+		{
+			name: "Should format to seconds with negative precision",
+			args: args{t: input, precision: -1},
+			want: "2021-12-11T21:10:10Z",
+		},
+		{
+			name: "Should format with three digits",
+			args: args{t: input, precision: 3},
+			want: "2021-12-11T21:10:10.123Z",
+		},
+		{
+			name: "Should format with seven digits",
+			args: args{t: input, precision: MaxPrecision},
+			want: "2021-12-11T21:10:10.1234567Z",
+		},
+		{
+			name: "Should clamp precision to seven digits",
+			args: args{t: input, precision: 9},
+			want: "2021-12-11T21:10:10.1234567Z",
+		},
+		{
+			name: "Should keep trailing zeros",
+			args: args{t: input.Truncate(time.Second), precision: MaxPrecision},
+			want: "2021-12-11T21:10:10.0000000Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToStringWithPrecision(tt.args.t, tt.args.precision); got != tt.want {
+				t.Errorf("ToStringWithPrecision()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []time.Time{
+		time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC),
+		time.Date(2021, 12, 12, 10, 10, 10, 123456789, time.UTC),
+		time.Date(2020, 2, 29, 23, 59, 59, 999999999, time.FixedZone("", -9*60*60-30*60)),
+		time.Date(1999, 1, 1, 0, 0, 0, 100, time.FixedZone("", 14*60*60)),
+	}
+
+	for _, input := range inputs {
+		for precision := 0; precision <= MaxPrecision; precision++ {
+			formatted := ToStringWithPrecision(input, precision)
+			got, err := ParseISO8601DateTime(formatted)
+			if err != nil {
+				t.Fatalf("ParseISO8601DateTime(%q) unexpected error: %v", formatted, err)
+			}
+
+			want := input.Truncate(time.Second)
+			if precision > 0 {
+				unit := time.Duration(1)
+				for i := precision; i < 9; i++ {
+					unit *= 10
+				}
+				want = input.Truncate(unit)
+			}
+
+			if !got.Equal(want) {
+				t.Errorf("round trip of %q\ngot:  = %v\nwant: %v\n", formatted, got, want)
+			}
+
+			if reformatted := ToStringWithPrecision(got, precision); reformatted != formatted {
+				t.Errorf("round trip format\ngot:  = %v\nwant: %v\n", reformatted, formatted)
+			}
+		}
+	}
+}
+
+func TestParseISO8601DateTimeInZone(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skipf("timezone database unavailable: %v", err)
+	}
+
+	type args struct {
+		dateTime string
+		zone     string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    time.Time
+		wantErr error
+	}{
+		{
+			name:    "Should error on an empty zone",
+			args:    args{dateTime: "2021-12-12", zone: ""},
+			wantErr: ErrUnknownTimeZone,
+		},
+		{
+			name:    "Should error on an unknown zone",
+			args:    args{dateTime: "2021-12-12", zone: "Middle/Earth"},
+			wantErr: ErrUnknownTimeZone,
+		},
+		{
+			name: "Should parse a date in the given zone",
+			args: args{dateTime: "2021-12-12", zone: "Pacific/Auckland"},
+			want: time.Date(2021, 12, 11, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a date in UTC",
+			args: args{dateTime: "2021-12-12T10:10", zone: "UTC"},
+			want: time.Date(2021, 12, 12, 10, 10, 0, 0, time.UTC),
+		},
+		{
+			name: "Should prefer an explicit timezone designator",
+			args: args{dateTime: "2021-12-12T10:10Z", zone: "Pacific/Auckland"},
+			want: time.Date(2021, 12, 12, 10, 10, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse the instant before a daylight saving gap",
+			args: args{dateTime: "2021-09-26T01:59:59", zone: "Pacific/Auckland"},
+			want: time.Date(2021, 9, 26, 1, 59, 59, 0, auckland),
+		},
+		{
+			name:    "Should error on a time in a daylight saving gap",
+			args:    args{dateTime: "2021-09-26T02:30", zone: "Pacific/Auckland"},
+			wantErr: ErrDateTimeNonExistent,
+		},
+		{
+			name: "Should parse the instant after a daylight saving gap",
+			args: args{dateTime: "2021-09-26T03:00", zone: "Pacific/Auckland"},
+			want: time.Date(2021, 9, 25, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Should error on a time in a daylight saving overlap",
+			args:    args{dateTime: "2021-04-04T02:30", zone: "Pacific/Auckland"},
+			wantErr: ErrDateTimeAmbiguous,
+		},
+		{
+			name: "Should parse an explicit offset in a daylight saving overlap",
+			args: args{dateTime: "2021-04-04T02:30+12:00", zone: "Pacific/Auckland"},
+			want: time.Date(2021, 4, 3, 14, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse the instant after a daylight saving overlap",
+			args: args{dateTime: "2021-04-04T03:00", zone: "Pacific/Auckland"},
+			want: time.Date(2021, 4, 3, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a date skipped at midnight as the end of the daylight saving gap",
+			args: args{dateTime: "2022-09-11", zone: "America/Santiago"},
+			want: time.Date(2022, 9, 11, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse a reduced precision date skipped at midnight as the end of the daylight saving gap",
+			args: args{dateTime: "2022-W36-7", zone: "America/Santiago"},
+			want: time.Date(2022, 9, 11, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Should error on midnight in a daylight saving gap",
+			args:    args{dateTime: "2022-09-11T00:00", zone: "America/Santiago"},
+			wantErr: ErrDateTimeNonExistent,
+		},
+		{
+			name: "Should parse a date following a daylight saving overlap before midnight",
+			args: args{dateTime: "2022-04-03", zone: "America/Santiago"},
+			want: time.Date(2022, 4, 3, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Should parse the date before a daylight saving overlap",
+			args: args{dateTime: "2022-04-02", zone: "America/Santiago"},
+			want: time.Date(2022, 4, 2, 3, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseISO8601DateTimeInZone(tt.args.dateTime, tt.args.zone)
+			if err != tt.wantErr {
+				t.Errorf("ParseISO8601DateTimeInZone() error\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseISO8601DateTimeInZone() time\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,462 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aztime
+
+import (
+	// Standard Library Imports
+	"strings"
+	"time"
+)
+
+// isoDateTime holds the components of a parsed ISO 8601 date time before it is
+// bound to a location.
+type isoDateTime struct {
+	year       int
+	month      time.Month
+	day        int
+	hour       int
+	minute     int
+	second     int
+	nanosecond int
+	// endOfDay is set when the time is specified as 24:00.
+	endOfDay bool
+	// dateOnly is set when no time was provided.
+	dateOnly bool
+	// zone is nil if no timezone designator was provided.
+	zone *time.Location
+}
+
+// parseISO8601 parses a practical ISO 8601 profile date time. Date times
+// without a timezone designator are interpreted in the provided location.
+func parseISO8601(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, ErrDateTimeEmpty
+	}
+
+	dt, err := parseISO8601Components(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if dt.endOfDay {
+		// Roll the calendar date over before binding to a location, so that
+		// midnight is resolved against the correct day.
+		next := time.Date(dt.year, dt.month, dt.day+1, 0, 0, 0, 0, time.UTC)
+		dt.year, dt.month, dt.day = next.Year(), next.Month(), next.Day()
+	}
+
+	if dt.zone != nil {
+		// An explicit timezone designator always identifies a single instant.
+		return time.Date(dt.year, dt.month, dt.day, dt.hour, dt.minute, dt.second, dt.nanosecond, dt.zone), nil
+	}
+
+	if dt.dateOnly {
+		return startOfDay(dt, loc), nil
+	}
+
+	return resolveWallClock(dt, loc)
+}
+
+// startOfDay returns the first instant of a calendar date in the given
+// location. Dates don't name a wall clock time, so a daylight saving gap or
+// overlap at midnight doesn't make them non-existent or ambiguous, instead the
+// day starts when the first wall clock time of that date occurs.
+func startOfDay(dt isoDateTime, loc *time.Location) time.Time {
+	t := time.Date(dt.year, dt.month, dt.day, 0, 0, 0, 0, loc)
+	wall := time.Date(dt.year, dt.month, dt.day, 0, 0, 0, 0, time.UTC)
+
+	var first time.Time
+	for _, probe := range []time.Time{t.Add(-24 * time.Hour), t, t.Add(24 * time.Hour)} {
+		_, offset := probe.Zone()
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if y, m, d := candidate.Date(); y != dt.year || m != dt.month || d != dt.day {
+			// Midnight was skipped, so this offset resolves to the prior day.
+			continue
+		}
+
+		if first.IsZero() || candidate.Before(first) {
+			first = candidate
+		}
+	}
+
+	return first
+}
+
+// resolveWallClock binds a wall clock time to the given location, reporting
+// wall clock times that don't exist (skipped by a daylight saving gap) or are
+// ambiguous (repeated by a daylight saving overlap), rather than letting
+// time.Date silently pick one.
+func resolveWallClock(dt isoDateTime, loc *time.Location) (time.Time, error) {
+	t := time.Date(dt.year, dt.month, dt.day, dt.hour, dt.minute, dt.second, dt.nanosecond, loc)
+	wall := time.Date(dt.year, dt.month, dt.day, dt.hour, dt.minute, dt.second, dt.nanosecond, time.UTC)
+
+	// Gather the offsets in effect around the wall clock time, then work out
+	// which of them map the wall clock time back onto itself.
+	var matches []time.Time
+	seen := map[int]bool{}
+	for _, probe := range []time.Time{t.Add(-24 * time.Hour), t, t.Add(24 * time.Hour)} {
+		_, offset := probe.Zone()
+		if seen[offset] {
+			continue
+		}
+		seen[offset] = true
+
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if _, candidateOffset := candidate.Zone(); candidateOffset == offset {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return time.Time{}, ErrDateTimeNonExistent
+
+	case 1:
+		return matches[0], nil
+
+	default:
+		return time.Time{}, ErrDateTimeAmbiguous
+	}
+}
+
+func parseISO8601Components(value string) (dt isoDateTime, err error) {
+	p := &isoParser{in: value}
+	if err = p.parseDate(&dt); err != nil {
+		return dt, err
+	}
+
+	if p.consumeAny("Tt ") {
+		if err = p.parseTime(&dt); err != nil {
+			return dt, err
+		}
+	} else {
+		dt.dateOnly = true
+	}
+
+	if !p.done() {
+		if dt.zone, err = p.parseZone(); err != nil {
+			return dt, err
+		}
+	}
+
+	if !p.done() {
+		// Trailing garbage.
+		return dt, ErrInvalidDateTimeFormat
+	}
+
+	return dt, nil
+}
+
+// isoParser is a minimal cursor over an ISO 8601 formatted string.
+type isoParser struct {
+	in  string
+	pos int
+}
+
+func (p *isoParser) done() bool {
+	return p.pos >= len(p.in)
+}
+
+func (p *isoParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+
+	return p.in[p.pos]
+}
+
+// consumeAny advances the cursor if the next character is one of chars.
+func (p *isoParser) consumeAny(chars string) bool {
+	if !p.done() && strings.IndexByte(chars, p.peek()) >= 0 {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// countDigits returns the number of consecutive digits from the cursor.
+func (p *isoParser) countDigits() int {
+	n := 0
+	for p.pos+n < len(p.in) && isDigit(p.in[p.pos+n]) {
+		n++
+	}
+
+	return n
+}
+
+// number consumes exactly n digits.
+func (p *isoParser) number(n int) (int, error) {
+	if p.countDigits() < n {
+		return 0, ErrInvalidDateTimeFormat
+	}
+
+	v := 0
+	for _, c := range p.in[p.pos : p.pos+n] {
+		v = v*10 + int(c-'0')
+	}
+	p.pos += n
+
+	return v, nil
+}
+
+func (p *isoParser) parseDate(dt *isoDateTime) (err error) {
+	if dt.year, err = p.number(4); err != nil {
+		return err
+	}
+
+	// Default to the first of January for reduced precision dates.
+	dt.month, dt.day = time.January, 1
+
+	extended := p.consumeAny("-")
+	if p.consumeAny("Ww") {
+		return p.parseWeekDate(dt, extended)
+	}
+
+	switch digits := p.countDigits(); {
+	case extended && digits == 2:
+		// YYYY-MM[-DD]
+		month, _ := p.number(2)
+		dt.month = time.Month(month)
+		if p.peek() == '-' && p.pos+1 < len(p.in) && isDigit(p.in[p.pos+1]) {
+			p.pos++
+			if dt.day, err = p.number(2); err != nil {
+				return err
+			}
+		}
+
+		return validateCalendarDate(dt)
+
+	case !extended && digits >= 4 && digits != 7:
+		// YYYYMMDD
+		month, _ := p.number(2)
+		dt.month = time.Month(month)
+		dt.day, _ = p.number(2)
+
+		return validateCalendarDate(dt)
+
+	case digits == 3 || (!extended && digits == 7):
+		// YYYY-DDD or YYYYDDD
+		ordinal, _ := p.number(3)
+		return setOrdinalDate(dt, ordinal)
+
+	case !extended && digits == 0:
+		// YYYY
+		return nil
+
+	default:
+		return ErrInvalidDateTimeFormat
+	}
+}
+
+func (p *isoParser) parseWeekDate(dt *isoDateTime, extended bool) error {
+	week, err := p.number(2)
+	if err != nil {
+		return err
+	}
+
+	weekday := 1
+	if extended {
+		if p.peek() == '-' && p.pos+1 < len(p.in) && isDigit(p.in[p.pos+1]) {
+			p.pos++
+			weekday, _ = p.number(1)
+		}
+	} else if p.countDigits() >= 1 {
+		weekday, _ = p.number(1)
+	}
+
+	return setWeekDate(dt, week, weekday)
+}
+
+func (p *isoParser) parseTime(dt *isoDateTime) (err error) {
+	if dt.hour, err = p.number(2); err != nil {
+		return err
+	}
+
+	extended := p.peek() == ':'
+	if extended {
+		p.pos++
+		if dt.minute, err = p.number(2); err != nil {
+			return err
+		}
+
+		if p.consumeAny(":") {
+			if dt.second, err = p.number(2); err != nil {
+				return err
+			}
+
+			if err = p.parseFraction(dt); err != nil {
+				return err
+			}
+		}
+	} else if p.countDigits() >= 2 {
+		dt.minute, _ = p.number(2)
+		if p.countDigits() >= 2 {
+			dt.second, _ = p.number(2)
+			if err = p.parseFraction(dt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return validateTime(dt)
+}
+
+// parseFraction parses optional fractional seconds, supporting any number of
+// digits, truncating to nanosecond precision.
+func (p *isoParser) parseFraction(dt *isoDateTime) error {
+	if !p.consumeAny(".,") {
+		return nil
+	}
+
+	digits := p.countDigits()
+	if digits == 0 {
+		return ErrInvalidDateTimeFormat
+	}
+
+	ns := 0
+	for i := 0; i < 9; i++ {
+		ns *= 10
+		if i < digits {
+			ns += int(p.in[p.pos+i] - '0')
+		}
+	}
+	p.pos += digits
+	dt.nanosecond = ns
+
+	return nil
+}
+
+func (p *isoParser) parseZone() (*time.Location, error) {
+	if p.consumeAny("Zz") {
+		return time.UTC, nil
+	}
+
+	sign := 1
+	switch {
+	case p.consumeAny("+"):
+	case p.consumeAny("-"):
+		sign = -1
+	default:
+		return nil, ErrInvalidDateTimeFormat
+	}
+
+	hours, err := p.number(2)
+	if err != nil {
+		return nil, err
+	}
+
+	minutes := 0
+	if p.consumeAny(":") {
+		if minutes, err = p.number(2); err != nil {
+			return nil, err
+		}
+	} else if p.countDigits() > 0 {
+		if minutes, err = p.number(2); err != nil {
+			return nil, err
+		}
+	}
+
+	if hours > 23 || minutes > 59 {
+		return nil, ErrInvalidDateTimeFormat
+	}
+
+	offset := sign * (hours*60*60 + minutes*60)
+	if offset == 0 {
+		return time.UTC, nil
+	}
+
+	return time.FixedZone("", offset), nil
+}
+
+func validateCalendarDate(dt *isoDateTime) error {
+	if dt.month < time.January || dt.month > time.December {
+		return ErrInvalidDateTimeFormat
+	}
+
+	if dt.day < 1 || dt.day > daysIn(dt.year, dt.month) {
+		return ErrInvalidDateTimeFormat
+	}
+
+	return nil
+}
+
+func setOrdinalDate(dt *isoDateTime, ordinal int) error {
+	daysInYear := 365
+	if daysIn(dt.year, time.February) == 29 {
+		daysInYear = 366
+	}
+
+	if ordinal < 1 || ordinal > daysInYear {
+		return ErrInvalidDateTimeFormat
+	}
+
+	t := time.Date(dt.year, time.January, ordinal, 0, 0, 0, 0, time.UTC)
+	dt.month, dt.day = t.Month(), t.Day()
+
+	return nil
+}
+
+func setWeekDate(dt *isoDateTime, week int, weekday int) error {
+	if week < 1 || week > 53 || weekday < 1 || weekday > 7 {
+		return ErrInvalidDateTimeFormat
+	}
+
+	// Week 1 is the week containing the 4th of January.
+	jan4 := time.Date(dt.year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	isoWeekday := int(jan4.Weekday())
+	if isoWeekday == 0 {
+		// ISO 8601 weeks start on a Monday, making Sunday day 7.
+		isoWeekday = 7
+	}
+
+	t := jan4.AddDate(0, 0, (week-1)*7+(weekday-1)-(isoWeekday-1))
+	if y, w := t.ISOWeek(); y != dt.year || w != week {
+		// Week 53 doesn't exist in the given year.
+		return ErrInvalidDateTimeFormat
+	}
+
+	dt.year, dt.month, dt.day = t.Year(), t.Month(), t.Day()
+
+	return nil
+}
+
+func validateTime(dt *isoDateTime) error {
+	if dt.hour == 24 {
+		if dt.minute != 0 || dt.second != 0 || dt.nanosecond != 0 {
+			return ErrInvalidDateTimeFormat
+		}
+
+		dt.hour, dt.endOfDay = 0, true
+		return nil
+	}
+
+	if dt.hour > 23 || dt.minute > 59 || dt.second > 59 {
+		return ErrInvalidDateTimeFormat
+	}
+
+	return nil
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
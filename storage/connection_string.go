@@ -0,0 +1,394 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	// Standard Library Imports
+	"net/url"
+	"strings"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+	"github.com/matthewhartstonge/sassy/storage/endpoints"
+	"github.com/matthewhartstonge/sassy/storage/services"
+)
+
+// Connection string keys.
+// Refer: https://docs.microsoft.com/en-us/azure/storage/common/storage-configure-connection-string
+const (
+	ConnectionStringDefaultEndpointsProtocol   = "DefaultEndpointsProtocol"
+	ConnectionStringAccountName                = "AccountName"
+	ConnectionStringAccountKey                 = "AccountKey"
+	ConnectionStringEndpointSuffix             = "EndpointSuffix"
+	ConnectionStringBlobEndpoint               = "BlobEndpoint"
+	ConnectionStringQueueEndpoint              = "QueueEndpoint"
+	ConnectionStringTableEndpoint              = "TableEndpoint"
+	ConnectionStringFileEndpoint               = "FileEndpoint"
+	ConnectionStringSharedAccessSignature      = "SharedAccessSignature"
+	ConnectionStringUseDevelopmentStorage      = "UseDevelopmentStorage"
+	ConnectionStringDevelopmentStorageProxyURI = "DevelopmentStorageProxyUri"
+)
+
+// connectionStringKeys are the documented storage connection string keys. Any
+// other keys are ignored.
+var connectionStringKeys = []string{
+	ConnectionStringDefaultEndpointsProtocol,
+	ConnectionStringAccountName,
+	ConnectionStringAccountKey,
+	ConnectionStringEndpointSuffix,
+	ConnectionStringBlobEndpoint,
+	ConnectionStringQueueEndpoint,
+	ConnectionStringTableEndpoint,
+	ConnectionStringFileEndpoint,
+	ConnectionStringSharedAccessSignature,
+	ConnectionStringUseDevelopmentStorage,
+	ConnectionStringDevelopmentStorageProxyURI,
+}
+
+// Well-known development storage (Azurite/storage emulator) credentials.
+// Refer: https://docs.microsoft.com/en-us/azure/storage/common/storage-use-azurite#http-connection-strings
+const (
+	DevelopmentStorageAccountName = endpoints.DevelopmentStorageAccountName
+	DevelopmentStorageAccountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// ConnectionString holds a parsed Azure Storage connection string.
+type ConnectionString struct {
+	DefaultEndpointsProtocol string
+	AccountName              string
+	// AccountKey is nil if the connection string uses a shared access
+	// signature instead of an account key.
+	AccountKey            *crypto.Key
+	EndpointSuffix        string
+	BlobEndpoint          *url.URL
+	QueueEndpoint         *url.URL
+	TableEndpoint         *url.URL
+	FileEndpoint          *url.URL
+	SharedAccessSignature string
+	UseDevelopmentStorage bool
+	// DevelopmentStorageProxyURI is only set when UseDevelopmentStorage is
+	// enabled.
+	DevelopmentStorageProxyURI *url.URL
+}
+
+// ParseConnectionString parses an Azure Storage connection string, supporting
+// every documented key, including explicit service endpoints, development
+// storage and shared access signatures.
+//
+// Errors are returned as a *connstr.Error, naming the malformed part of the
+// connection string.
+func ParseConnectionString(connectionString string) (*ConnectionString, error) {
+	values, err := connstr.Parse(connectionString, connectionStringKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	if values.Has(ConnectionStringUseDevelopmentStorage) {
+		return parseDevelopmentStorageConnectionString(values)
+	}
+
+	cs := &ConnectionString{
+		DefaultEndpointsProtocol: "https",
+		AccountName:              values.Get(ConnectionStringAccountName),
+		EndpointSuffix:           values.Get(ConnectionStringEndpointSuffix),
+		SharedAccessSignature:    values.Get(ConnectionStringSharedAccessSignature),
+	}
+	if cs.AccountName == DevelopmentStorageAccountName {
+		// Development storage is served over HTTP unless told otherwise.
+		cs.DefaultEndpointsProtocol = "http"
+	}
+
+	if values.Has(ConnectionStringDefaultEndpointsProtocol) {
+		protocol := strings.ToLower(values.Get(ConnectionStringDefaultEndpointsProtocol))
+		if protocol != "http" && protocol != "https" {
+			return nil, &connstr.Error{Key: ConnectionStringDefaultEndpointsProtocol, Err: ErrInvalidEndpointsProtocol}
+		}
+
+		cs.DefaultEndpointsProtocol = protocol
+	}
+
+	endpoints := []struct {
+		key      string
+		endpoint **url.URL
+	}{
+		{key: ConnectionStringBlobEndpoint, endpoint: &cs.BlobEndpoint},
+		{key: ConnectionStringQueueEndpoint, endpoint: &cs.QueueEndpoint},
+		{key: ConnectionStringTableEndpoint, endpoint: &cs.TableEndpoint},
+		{key: ConnectionStringFileEndpoint, endpoint: &cs.FileEndpoint},
+	}
+	hasExplicitEndpoint := false
+	for _, e := range endpoints {
+		if !values.Has(e.key) {
+			continue
+		}
+
+		if *e.endpoint, err = parseEndpoint(e.key, values.Get(e.key)); err != nil {
+			return nil, err
+		}
+		hasExplicitEndpoint = true
+	}
+
+	if cs.AccountName == "" && !hasExplicitEndpoint {
+		// Without explicit endpoints, the account name is required to build
+		// the default service endpoints.
+		return nil, &connstr.Error{Key: ConnectionStringAccountName, Err: connstr.ErrMissingKey}
+	}
+
+	if values.Has(ConnectionStringDevelopmentStorageProxyURI) {
+		return nil, &connstr.Error{Key: ConnectionStringDevelopmentStorageProxyURI, Err: ErrDevelopmentStorageOnly}
+	}
+
+	hasKey := values.Has(ConnectionStringAccountKey)
+	hasSAS := values.Has(ConnectionStringSharedAccessSignature)
+	switch {
+	case hasKey && hasSAS:
+		return nil, &connstr.Error{Key: ConnectionStringSharedAccessSignature, Err: ErrAccountKeyAndSAS}
+
+	case hasKey:
+		if cs.AccountName == "" {
+			return nil, &connstr.Error{Key: ConnectionStringAccountName, Err: connstr.ErrMissingKey}
+		}
+
+		if cs.AccountKey, err = crypto.DecodeKey(values.Get(ConnectionStringAccountKey)); err != nil {
+			return nil, &connstr.Error{Key: ConnectionStringAccountKey, Err: ErrDecodingStorageAccountKey}
+		}
+
+	case hasSAS:
+		sas, err := url.ParseQuery(strings.TrimPrefix(cs.SharedAccessSignature, "?"))
+		if err != nil || sas.Get("sig") == "" {
+			return nil, &connstr.Error{Key: ConnectionStringSharedAccessSignature, Err: ErrInvalidSharedAccessSignature}
+		}
+
+		cs.SharedAccessSignature = strings.TrimPrefix(cs.SharedAccessSignature, "?")
+
+	default:
+		return nil, &connstr.Error{Key: ConnectionStringAccountKey, Err: connstr.ErrMissingKey}
+	}
+
+	return cs, nil
+}
+
+// Endpoints returns the service endpoints described by the connection string.
+// Explicit service endpoints take precedence over endpoints built from the
+// account name and endpoint suffix.
+//
+// The account name is required to build the endpoints of services without an
+// explicit endpoint. Connection strings which only hold explicit endpoints
+// return a *connstr.Error naming the missing account name, as the endpoints of
+// other services can't be known, for example, when using a custom domain. Use
+// the explicit endpoint fields directly instead.
+func (c *ConnectionString) Endpoints() (*endpoints.Endpoints, error) {
+	if c.UseDevelopmentStorage {
+		host := endpoints.DevelopmentStorageHost
+		if c.DevelopmentStorageProxyURI != nil {
+			host = c.DevelopmentStorageProxyURI.Hostname()
+		}
+
+		return endpoints.NewDevelopmentStorage(endpoints.WithDevelopmentStorage(host))
+	}
+
+	accountName := c.AccountName
+	if accountName == "" {
+		return nil, &connstr.Error{Key: ConnectionStringAccountName, Err: connstr.ErrMissingKey}
+	}
+
+	var opts []endpoints.Option
+	if accountName == DevelopmentStorageAccountName {
+		// Explicit endpoints for the well-known development storage account,
+		// for example, a docker hosted Azurite, use path-style URLs.
+		host := endpoints.DevelopmentStorageHost
+		for _, endpoint := range []*url.URL{c.BlobEndpoint, c.QueueEndpoint, c.TableEndpoint, c.FileEndpoint} {
+			if endpoint != nil {
+				host = endpoint.Hostname()
+				break
+			}
+		}
+
+		opts = append(opts, endpoints.WithDevelopmentStorage(host))
+	}
+
+	// Applied after development storage, which defaults to http.
+	opts = append(opts, endpoints.WithProtocol(c.DefaultEndpointsProtocol))
+
+	if c.EndpointSuffix != "" {
+		opts = append(opts, endpoints.WithSuffix(endpoints.Suffix(c.EndpointSuffix)))
+	}
+
+	ep, err := endpoints.New(accountName, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	explicit := map[endpoints.Service]*url.URL{
+		endpoints.Blob:  c.BlobEndpoint,
+		endpoints.Queue: c.QueueEndpoint,
+		endpoints.Table: c.TableEndpoint,
+		endpoints.File:  c.FileEndpoint,
+	}
+	for service, endpoint := range explicit {
+		if endpoint != nil {
+			ep.Custom[service] = endpoint
+		}
+	}
+
+	return ep, nil
+}
+
+// SASConnectionString builds a connection string containing the endpoint for
+// each granted service and the provided SAS token. This is the format expected
+// by tools such as Azure Storage Explorer, Azure Functions bindings and Azure
+// Data Factory.
+func SASConnectionString(
+	ep *endpoints.Endpoints,
+	signedServices services.SignedServices,
+	token string,
+) (string, error) {
+	if ep == nil {
+		return "", ErrEndpointsRequired
+	}
+
+	if len(signedServices) == 0 {
+		return "", ErrNoSignedServices
+	}
+
+	serviceKeys := map[endpoints.Service]string{
+		endpoints.Blob:  ConnectionStringBlobEndpoint,
+		endpoints.Queue: ConnectionStringQueueEndpoint,
+		endpoints.Table: ConnectionStringTableEndpoint,
+		endpoints.File:  ConnectionStringFileEndpoint,
+	}
+
+	values := connstr.Values{}
+	for _, signedService := range signedServices {
+		service, err := endpoints.FromSignedService(signedService)
+		if err != nil {
+			return "", err
+		}
+
+		values[serviceKeys[service]] = ep.URL(service).String()
+	}
+	values[ConnectionStringSharedAccessSignature] = strings.TrimPrefix(token, "?")
+
+	// Emit keys in the order documented by Microsoft.
+	return values.Encode(
+		ConnectionStringBlobEndpoint,
+		ConnectionStringQueueEndpoint,
+		ConnectionStringTableEndpoint,
+		ConnectionStringFileEndpoint,
+		ConnectionStringSharedAccessSignature,
+	), nil
+}
+
+// parseDevelopmentStorageConnectionString parses a connection string which
+// targets development storage, for example, `UseDevelopmentStorage=true`.
+func parseDevelopmentStorageConnectionString(values connstr.Values) (*ConnectionString, error) {
+	if !strings.EqualFold(values.Get(ConnectionStringUseDevelopmentStorage), "true") {
+		// UseDevelopmentStorage is only documented as being set to true.
+		return nil, &connstr.Error{Key: ConnectionStringUseDevelopmentStorage, Err: connstr.ErrInvalidValue}
+	}
+
+	for _, key := range connectionStringKeys {
+		switch key {
+		case ConnectionStringUseDevelopmentStorage, ConnectionStringDevelopmentStorageProxyURI:
+		default:
+			if values.Has(key) {
+				return nil, &connstr.Error{Key: key, Err: ErrDevelopmentStorageExclusive}
+			}
+		}
+	}
+
+	// The proxy is parsed before decoding the key, so the key doesn't have to
+	// be destroyed if the proxy is invalid.
+	var proxy *url.URL
+	if values.Has(ConnectionStringDevelopmentStorageProxyURI) {
+		var err error
+		proxy, err = parseEndpoint(ConnectionStringDevelopmentStorageProxyURI, values.Get(ConnectionStringDevelopmentStorageProxyURI))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := crypto.DecodeKey(DevelopmentStorageAccountKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConnectionString{
+		DefaultEndpointsProtocol:   "http",
+		AccountName:                DevelopmentStorageAccountName,
+		AccountKey:                 key,
+		UseDevelopmentStorage:      true,
+		DevelopmentStorageProxyURI: proxy,
+	}, nil
+}
+
+// parseEndpoint parses an absolute HTTP(S) endpoint.
+func parseEndpoint(key string, endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, &connstr.Error{Key: key, Err: ErrInvalidEndpoint}
+	}
+
+	return u, nil
+}
+
+// NewAccountSASFromConnectionString provides a way to generate an account
+// based Shared Access Signature (SAS) token from a storage connection string.
+// The connection string must contain an account name and account key.
+func NewAccountSASFromConnectionString(
+	connectionString string,
+	signedVersion string,
+	signedServices string,
+	signedResourceTypes string,
+	signedPermissions string,
+	signedExpiry string,
+	opts ...AccountSASOption,
+) (
+	accountSAS *AccountSAS,
+	err error,
+) {
+	cs, err := ParseConnectionString(connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	if cs.AccountKey == nil {
+		// A SAS can't be used to sign another SAS.
+		return nil, &connstr.Error{Key: ConnectionStringAccountKey, Err: connstr.ErrMissingKey}
+	}
+
+	ep, err := cs.Endpoints()
+	if err != nil {
+		cs.AccountKey.Destroy()
+		return nil, err
+	}
+
+	// Prepend, so that callers can still override the endpoints.
+	opts = append([]AccountSASOption{WithEndpoints(ep)}, opts...)
+
+	return NewAccountSASWithKey(
+		cs.AccountName,
+		cs.AccountKey,
+		signedVersion,
+		signedServices,
+		signedResourceTypes,
+		signedPermissions,
+		signedExpiry,
+		opts...,
+	)
+}
@@ -0,0 +1,366 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/endpoints"
+	"github.com/matthewhartstonge/sassy/storage/services"
+)
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		wantAccountName  string
+		wantProtocol     string
+		wantHasKey       bool
+		wantErrKey       string
+		wantErr          error
+	}{
+		{
+			name:             "Should parse an account key connection string",
+			connectionString: "DefaultEndpointsProtocol=https;AccountName=sassy;AccountKey=c2Fzc3k=;EndpointSuffix=core.windows.net",
+			wantAccountName:  "sassy",
+			wantProtocol:     "https",
+			wantHasKey:       true,
+		},
+		{
+			name:             "Should match keys case insensitively and ignore a trailing separator",
+			connectionString: "defaultendpointsprotocol=HTTP;accountname=sassy;accountkey=c2Fzc3k=;",
+			wantAccountName:  "sassy",
+			wantProtocol:     "http",
+			wantHasKey:       true,
+		},
+		{
+			name:             "Should parse a SAS connection string with explicit endpoints",
+			connectionString: "BlobEndpoint=https://sassy.blob.core.windows.net/;QueueEndpoint=https://sassy.queue.core.windows.net/;SharedAccessSignature=sv=2020-10-02&ss=bq&sig=c2lnbmF0dXJl",
+			wantProtocol:     "https",
+		},
+		{
+			name:             "Should parse development storage",
+			connectionString: "UseDevelopmentStorage=true",
+			wantAccountName:  DevelopmentStorageAccountName,
+			wantProtocol:     "http",
+			wantHasKey:       true,
+		},
+		{
+			name:             "Should parse development storage with a proxy",
+			connectionString: "UseDevelopmentStorage=true;DevelopmentStorageProxyUri=http://myProxyUri",
+			wantAccountName:  DevelopmentStorageAccountName,
+			wantProtocol:     "http",
+			wantHasKey:       true,
+		},
+		{
+			name:             "Should not allow an invalid development storage proxy",
+			connectionString: "UseDevelopmentStorage=true;DevelopmentStorageProxyUri=myProxyUri",
+			wantErrKey:       ConnectionStringDevelopmentStorageProxyURI,
+			wantErr:          ErrInvalidEndpoint,
+		},
+		{
+			name:             "Should name a malformed part by position",
+			connectionString: "AccountName=sassy;c2Fzc3k",
+			wantErrKey:       "part 2",
+			wantErr:          connstr.ErrMalformed,
+		},
+		{
+			name:             "Should ignore unknown keys",
+			connectionString: "DefaultEndpointsProtocol=https;AccountName=sassy;AccountKey=c2Fzc3k=;TransportType=Amqp",
+			wantAccountName:  "sassy",
+			wantProtocol:     "https",
+			wantHasKey:       true,
+		},
+		{
+			name:             "Should default the development storage account to http",
+			connectionString: "AccountName=devstoreaccount1;AccountKey=c2Fzc3k=;BlobEndpoint=http://azurite:10000/devstoreaccount1",
+			wantAccountName:  DevelopmentStorageAccountName,
+			wantProtocol:     "http",
+			wantHasKey:       true,
+		},
+		{
+			name:             "Should name a missing key when a known key is misspelt",
+			connectionString: "AccountName=sassy;AccountKye=c2Fzc3k=",
+			wantErrKey:       ConnectionStringAccountKey,
+			wantErr:          connstr.ErrMissingKey,
+		},
+		{
+			name:             "Should not allow a documented key with development storage",
+			connectionString: "UseDevelopmentStorage=true;TransportType=Amqp;AccountKey=c2Fzc3k=",
+			wantErrKey:       ConnectionStringAccountKey,
+			wantErr:          ErrDevelopmentStorageExclusive,
+		},
+		{
+			name:             "Should name a duplicate key",
+			connectionString: "AccountName=sassy;accountName=sassy;AccountKey=c2Fzc3k=",
+			wantErrKey:       ConnectionStringAccountName,
+			wantErr:          connstr.ErrDuplicateKey,
+		},
+		{
+			name:             "Should name an invalid protocol",
+			connectionString: "DefaultEndpointsProtocol=ftp;AccountName=sassy;AccountKey=c2Fzc3k=",
+			wantErrKey:       ConnectionStringDefaultEndpointsProtocol,
+			wantErr:          ErrInvalidEndpointsProtocol,
+		},
+		{
+			name:             "Should name an invalid account key",
+			connectionString: "AccountName=sassy;AccountKey=not base64",
+			wantErrKey:       ConnectionStringAccountKey,
+			wantErr:          ErrDecodingStorageAccountKey,
+		},
+		{
+			name:             "Should name a missing account key",
+			connectionString: "AccountName=sassy",
+			wantErrKey:       ConnectionStringAccountKey,
+			wantErr:          connstr.ErrMissingKey,
+		},
+		{
+			name:             "Should name a missing account name",
+			connectionString: "AccountKey=c2Fzc3k=",
+			wantErrKey:       ConnectionStringAccountName,
+			wantErr:          connstr.ErrMissingKey,
+		},
+		{
+			name:             "Should name an invalid endpoint",
+			connectionString: "BlobEndpoint=sassy.blob.core.windows.net;SharedAccessSignature=sig=c2lnbmF0dXJl",
+			wantErrKey:       ConnectionStringBlobEndpoint,
+			wantErr:          ErrInvalidEndpoint,
+		},
+		{
+			name:             "Should name an unsigned shared access signature",
+			connectionString: "AccountName=sassy;SharedAccessSignature=sv=2020-10-02",
+			wantErrKey:       ConnectionStringSharedAccessSignature,
+			wantErr:          ErrInvalidSharedAccessSignature,
+		},
+		{
+			name:             "Should not allow an account key and shared access signature",
+			connectionString: "AccountName=sassy;AccountKey=c2Fzc3k=;SharedAccessSignature=sig=c2lnbmF0dXJl",
+			wantErrKey:       ConnectionStringSharedAccessSignature,
+			wantErr:          ErrAccountKeyAndSAS,
+		},
+		{
+			name:             "Should not allow an account with development storage",
+			connectionString: "UseDevelopmentStorage=true;AccountName=sassy",
+			wantErrKey:       ConnectionStringAccountName,
+			wantErr:          ErrDevelopmentStorageExclusive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionString(tt.connectionString)
+			if tt.wantErr != nil {
+				var csErr *connstr.Error
+				if !errors.As(err, &csErr) || !errors.Is(err, tt.wantErr) || csErr.Key != tt.wantErrKey {
+					t.Fatalf("ParseConnectionString() error\ngot:  = %v\nwant: connection string %s: %v\n", err, tt.wantErrKey, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseConnectionString() unexpected error: %v", err)
+			}
+			if got.AccountName != tt.wantAccountName {
+				t.Errorf("ParseConnectionString() account name\ngot:  = %v\nwant: %v\n", got.AccountName, tt.wantAccountName)
+			}
+			if got.DefaultEndpointsProtocol != tt.wantProtocol {
+				t.Errorf("ParseConnectionString() protocol\ngot:  = %v\nwant: %v\n", got.DefaultEndpointsProtocol, tt.wantProtocol)
+			}
+			if (got.AccountKey != nil) != tt.wantHasKey {
+				t.Errorf("ParseConnectionString() has key\ngot:  = %v\nwant: %v\n", got.AccountKey != nil, tt.wantHasKey)
+			}
+		})
+	}
+}
+
+func TestConnectionString_Endpoints(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		want             map[endpoints.Service]string
+		wantErrKey       string
+		wantErr          error
+	}{
+		{
+			name:             "Should build endpoints from the account name",
+			connectionString: "AccountName=sassy;AccountKey=c2Fzc3k=;EndpointSuffix=core.chinacloudapi.cn",
+			want: map[endpoints.Service]string{
+				endpoints.Blob:  "https://sassy.blob.core.chinacloudapi.cn/",
+				endpoints.Queue: "https://sassy.queue.core.chinacloudapi.cn/",
+			},
+		},
+		{
+			name:             "Should prefer explicit endpoints over the account name",
+			connectionString: "AccountName=sassy;AccountKey=c2Fzc3k=;BlobEndpoint=https://assets.example.com",
+			want: map[endpoints.Service]string{
+				endpoints.Blob:  "https://assets.example.com/",
+				endpoints.Queue: "https://sassy.queue.core.windows.net/",
+			},
+		},
+		{
+			name:             "Should use http for explicit development storage endpoints",
+			connectionString: "AccountName=devstoreaccount1;AccountKey=c2Fzc3k=;BlobEndpoint=http://azurite:10000/devstoreaccount1",
+			want: map[endpoints.Service]string{
+				endpoints.Blob:  "http://azurite:10000/devstoreaccount1",
+				endpoints.Queue: "http://azurite:10001/devstoreaccount1/",
+			},
+		},
+		{
+			name:             "Should require an account name to build endpoints which weren't provided",
+			connectionString: "BlobEndpoint=https://assets.example.com;SharedAccessSignature=sv=2020-10-02&ss=b&sig=c2lnbmF0dXJl",
+			wantErrKey:       ConnectionStringAccountName,
+			wantErr:          connstr.ErrMissingKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := ParseConnectionString(tt.connectionString)
+			if err != nil {
+				t.Fatalf("ParseConnectionString() unexpected error: %v", err)
+			}
+
+			got, err := cs.Endpoints()
+			if tt.wantErr != nil {
+				var csErr *connstr.Error
+				if !errors.As(err, &csErr) || !errors.Is(err, tt.wantErr) || csErr.Key != tt.wantErrKey {
+					t.Fatalf("Endpoints() error\ngot:  = %v\nwant: connection string %s: %v\n", err, tt.wantErrKey, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Endpoints() unexpected error: %v", err)
+			}
+			for service, want := range tt.want {
+				if u := got.URL(service).String(); u != want {
+					t.Errorf("Endpoints() %s\ngot:  = %v\nwant: %v\n", service, u, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSASConnectionString(t *testing.T) {
+	ep, err := endpoints.New("sassy")
+	if err != nil {
+		t.Fatalf("endpoints.New() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		ep             *endpoints.Endpoints
+		signedServices string
+		token          string
+		want           string
+		wantErr        error
+	}{
+		{
+			name:           "Should only include the endpoint for the signed service",
+			ep:             ep,
+			signedServices: "q",
+			token:          "sv=2020-10-02&ss=q&sig=c2lnbmF0dXJl",
+			want:           "QueueEndpoint=https://sassy.queue.core.windows.net/;SharedAccessSignature=sv=2020-10-02&ss=q&sig=c2lnbmF0dXJl",
+		},
+		{
+			name:           "Should order endpoints as documented, regardless of the signed service order",
+			ep:             ep,
+			signedServices: "ftqb",
+			token:          "sv=2020-10-02&ss=bfqt&sig=c2lnbmF0dXJl",
+			want: "BlobEndpoint=https://sassy.blob.core.windows.net/;QueueEndpoint=https://sassy.queue.core.windows.net/;" +
+				"TableEndpoint=https://sassy.table.core.windows.net/;FileEndpoint=https://sassy.file.core.windows.net/;" +
+				"SharedAccessSignature=sv=2020-10-02&ss=bfqt&sig=c2lnbmF0dXJl",
+		},
+		{
+			name:           "Should strip a leading question mark from the token",
+			ep:             ep,
+			signedServices: "bt",
+			token:          "?sv=2020-10-02&ss=bt&sig=c2lnbmF0dXJl",
+			want: "BlobEndpoint=https://sassy.blob.core.windows.net/;TableEndpoint=https://sassy.table.core.windows.net/;" +
+				"SharedAccessSignature=sv=2020-10-02&ss=bt&sig=c2lnbmF0dXJl",
+		},
+		{
+			name:           "Should require endpoints",
+			signedServices: "b",
+			token:          "sig=c2lnbmF0dXJl",
+			wantErr:        ErrEndpointsRequired,
+		},
+		{
+			name:    "Should require a signed service",
+			ep:      ep,
+			token:   "sig=c2lnbmF0dXJl",
+			wantErr: ErrNoSignedServices,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SASConnectionString(tt.ep, services.Parse(tt.signedServices), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SASConnectionString() error\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SASConnectionString()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountSAS_ConnectionString(t *testing.T) {
+	sas, err := NewAccountSAS(
+		"sassy",
+		"c2Fzc3ktc3RvcmFnZS1rZXktMDEyMzQ1Njc4OWFiY2RlZg==",
+		"2020-10-02",
+		"fb",
+		"sco",
+		"rl",
+		"2099-12-12T10:00:00Z",
+		WithSignedStart("2021-12-12T10:00:00Z"),
+	)
+	if err != nil {
+		t.Fatalf("NewAccountSAS() unexpected error: %v", err)
+	}
+	defer sas.Close()
+
+	token, err := sas.Token()
+	if err != nil {
+		t.Fatalf("Token() unexpected error: %v", err)
+	}
+
+	got, err := sas.ConnectionString()
+	if err != nil {
+		t.Fatalf("ConnectionString() unexpected error: %v", err)
+	}
+
+	want := "BlobEndpoint=https://sassy.blob.core.windows.net/;FileEndpoint=https://sassy.file.core.windows.net/;" +
+		"SharedAccessSignature=" + token
+	if got != want {
+		t.Errorf("ConnectionString()\ngot:  = %v\nwant: %v\n", got, want)
+	}
+
+	cs, err := ParseConnectionString(got)
+	if err != nil {
+		t.Fatalf("ParseConnectionString() unexpected error: %v", err)
+	}
+	if cs.SharedAccessSignature != token || cs.QueueEndpoint != nil || cs.TableEndpoint != nil {
+		t.Errorf("ParseConnectionString() didn't round trip the connection string: %+v", cs)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestKey_Redaction(t *testing.T) {
+	key, err := DecodeKey("c2VjcmV0LXN0b3JhZ2Uta2V5")
+	if err != nil {
+		t.Fatalf("DecodeKey() unexpected error: %v", err)
+	}
+
+	type holder struct {
+		Key *Key
+	}
+
+	jsonOut, _ := json.Marshal(holder{Key: key})
+	logOut := &bytes.Buffer{}
+	slog.New(slog.NewTextHandler(logOut, nil)).Info("key", "key", key)
+
+	outputs := map[string]string{
+		"%s":   fmt.Sprintf("%s", key),
+		"%v":   fmt.Sprintf("%v", key),
+		"%+v":  fmt.Sprintf("%+v", holder{Key: key}),
+		"%#v":  fmt.Sprintf("%#v", key),
+		"%x":   fmt.Sprintf("%x", key),
+		"%q":   fmt.Sprintf("%q", key),
+		"json": string(jsonOut),
+		"slog": logOut.String(),
+	}
+
+	for name, out := range outputs {
+		if strings.Contains(out, "secret") || strings.Contains(out, "736563726574") {
+			t.Errorf("%s output leaked key material: %s", name, out)
+		}
+		if !strings.Contains(out, redacted) {
+			t.Errorf("%s output not redacted: %s", name, out)
+		}
+	}
+}
+
+func TestKey_Destroy(t *testing.T) {
+	key, err := NewKey([]byte("secret"))
+	if err != nil {
+		t.Fatalf("NewKey() unexpected error: %v", err)
+	}
+
+	signer, err := NewKeySigner(key)
+	if err != nil {
+		t.Fatalf("NewKeySigner() unexpected error: %v", err)
+	}
+
+	if _, err = signer.Sign(context.Background(), []byte("message")); err != nil {
+		t.Fatalf("Sign() unexpected error: %v", err)
+	}
+
+	var material []byte
+	_ = key.use(func(b []byte) error {
+		material = b
+		return nil
+	})
+
+	key.Destroy()
+	if !bytes.Equal(material, make([]byte, len(material))) {
+		t.Errorf("Destroy() did not zero key material")
+	}
+
+	if _, err = signer.Sign(context.Background(), []byte("message")); err != ErrKeyDestroyed {
+		t.Errorf("Sign() error\ngot:  = %v\nwant: %v\n", err, ErrKeyDestroyed)
+	}
+}
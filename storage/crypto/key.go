@@ -0,0 +1,155 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crypto
+
+import (
+	// Standard Library Imports
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	ErrKeyDestroyed = errors.New("key has been destroyed")
+)
+
+// Key holds secret key material, for example, a storage account key.
+//
+// Key is designed to limit the number of copies of the key material held in
+// memory. It must only be passed around by pointer, can be zeroed explicitly
+// with Close or Destroy, and is zeroed when garbage collected. It redacts
+// itself when formatted with fmt, marshalled to JSON or text, and logged with
+// log/slog.
+type Key struct {
+	mu sync.RWMutex
+	b  []byte
+}
+
+// NewKey returns a key which takes ownership of the provided key material. The
+// caller must not use or retain the slice after calling NewKey.
+func NewKey(key []byte) (*Key, error) {
+	if len(key) == 0 {
+		return nil, ErrSignerKeyEmpty
+	}
+
+	k := &Key{b: key}
+	runtime.SetFinalizer(k, (*Key).Destroy)
+
+	return k, nil
+}
+
+// DecodeKey decodes a base64 encoded key, for example, a storage account key.
+// The decoded key material is only held by the returned key, and the working
+// copy of the encoded key is zeroed. The encoded string itself is immutable, so
+// it can't be zeroed and remains in memory until garbage collected. Where that
+// matters, decode the key into a byte slice and pass it to NewKey instead.
+func DecodeKey(encoded string) (*Key, error) {
+	src := []byte(encoded)
+	defer clear(src)
+
+	dst := make([]byte, base64.StdEncoding.DecodedLen(len(src)))
+	n, err := base64.StdEncoding.Decode(dst, src)
+	if err != nil {
+		clear(dst)
+		return nil, ErrKeyDecoding
+	}
+
+	return NewKey(dst[:n])
+}
+
+// Len returns the length of the key material in bytes, or zero if the key has
+// been destroyed.
+func (k *Key) Len() int {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return len(k.b)
+}
+
+// Destroyed reports whether the key material has been zeroed.
+func (k *Key) Destroyed() bool {
+	return k.Len() == 0
+}
+
+// Destroy zeroes the key material. Any subsequent use of the key returns
+// ErrKeyDestroyed.
+func (k *Key) Destroy() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	clear(k.b)
+	k.b = nil
+}
+
+// Close implements io.Closer, zeroing the key material.
+func (k *Key) Close() error {
+	k.Destroy()
+	return nil
+}
+
+// use provides scoped access to the key material. The slice must not be
+// retained after fn returns.
+func (k *Key) use(fn func(key []byte) error) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if len(k.b) == 0 {
+		return ErrKeyDestroyed
+	}
+
+	return fn(k.b)
+}
+
+// String implements Stringer, redacting the key material.
+func (k *Key) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer, redacting the key material.
+func (k *Key) GoString() string {
+	return "crypto.Key{" + redacted + "}"
+}
+
+// Format implements fmt.Formatter, redacting the key material for every verb.
+func (k *Key) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = f.Write([]byte(k.GoString()))
+		return
+	}
+
+	_, _ = f.Write([]byte(redacted))
+}
+
+// MarshalJSON implements json.Marshaler, redacting the key material.
+func (k *Key) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// MarshalText implements encoding.TextMarshaler, redacting the key material.
+func (k *Key) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// LogValue implements slog.LogValuer, redacting the key material.
+func (k *Key) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
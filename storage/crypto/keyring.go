@@ -0,0 +1,292 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crypto
+
+import (
+	// Standard Library Imports
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyringEmpty       = errors.New("keyring does not contain any keys")
+	ErrKeyNameEmpty       = errors.New("key name must not be empty")
+	ErrKeyExists          = errors.New("a key with the same name already exists in the keyring")
+	ErrKeyNotFound        = errors.New("key not found in the keyring")
+	ErrKeyDecoding        = errors.New("error decoding key, must be base64 encoded")
+	ErrKeyIsPrimary       = errors.New("the primary key can not be removed, set another key as primary first")
+	ErrKeyExpiryUntracked = errors.New("key has signed messages without an expiry, so it's unknown when they expire")
+)
+
+// Keyring holds a set of named keys, for example, a storage account's key1 and
+// key2, to support rotation-aware signing and verification.
+//
+// Signing always uses the primary key, while verification tries every key,
+// reporting which key matched. The keyring tracks the latest expiry signed
+// with each key, so it can report when every token signed with a retiring key
+// will have expired. Tracking is held in memory and is per process only, so
+// only covers signatures made by this keyring since it was created, not those
+// made by other processes or before a restart.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    []*keyringEntry
+	primary *keyringEntry
+}
+
+type keyringEntry struct {
+	name string
+	key  *Key
+	// signedUntil is the latest expiry of any signature made with the key.
+	signedUntil time.Time
+	// untracked is set if the key has signed a message without an expiry.
+	untracked bool
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{}
+}
+
+// Add adds a named key to the keyring. The first key added becomes the primary
+// key. The keyring takes ownership of the key, destroying it when the key is
+// removed, the keyring is closed, or the key can't be added.
+func (k *Keyring) Add(name string, key *Key) error {
+	if key == nil || key.Destroyed() {
+		return ErrSignerKeyEmpty
+	}
+
+	if name == "" {
+		key.Destroy()
+		return ErrKeyNameEmpty
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.find(name) != nil {
+		key.Destroy()
+		return ErrKeyExists
+	}
+
+	entry := &keyringEntry{name: name, key: key}
+	k.keys = append(k.keys, entry)
+	if k.primary == nil {
+		k.primary = entry
+	}
+
+	return nil
+}
+
+// AddBase64 decodes a base64 encoded key, for example, a storage account key,
+// and adds it to the keyring.
+func (k *Keyring) AddBase64(name string, key string) error {
+	decoded, err := DecodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	return k.Add(name, decoded)
+}
+
+// Remove removes a named key from the keyring. The primary key can't be
+// removed.
+func (k *Keyring) Remove(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i, entry := range k.keys {
+		if entry.name != name {
+			continue
+		}
+
+		if entry == k.primary {
+			return ErrKeyIsPrimary
+		}
+
+		k.keys = append(k.keys[:i], k.keys[i+1:]...)
+		entry.key.Destroy()
+
+		return nil
+	}
+
+	return ErrKeyNotFound
+}
+
+// Close implements io.Closer, destroying every key in the keyring.
+func (k *Keyring) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, entry := range k.keys {
+		entry.key.Destroy()
+	}
+
+	k.keys = nil
+	k.primary = nil
+
+	return nil
+}
+
+// SetPrimary sets the named key as the key used for signing.
+func (k *Keyring) SetPrimary(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	entry := k.find(name)
+	if entry == nil {
+		return ErrKeyNotFound
+	}
+
+	k.primary = entry
+
+	return nil
+}
+
+// Primary returns the name of the key used for signing.
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.primary == nil {
+		return ""
+	}
+
+	return k.primary.name
+}
+
+// Names returns the names of the keys in the keyring, in the order they were
+// added.
+func (k *Keyring) Names() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	names := make([]string, 0, len(k.keys))
+	for _, entry := range k.keys {
+		names = append(names, entry.name)
+	}
+
+	return names
+}
+
+// Sign implements Signer, signing with the primary key.
+//
+// As the signature's expiry is unknown, the primary key is marked as having
+// signed an untracked message. Prefer SignWithExpiry.
+func (k *Keyring) Sign(ctx context.Context, message []byte) (signature string, err error) {
+	return k.sign(ctx, message, time.Time{})
+}
+
+// SignWithExpiry implements ExpirySigner, signing with the primary key and
+// recording the signature's expiry against it.
+func (k *Keyring) SignWithExpiry(ctx context.Context, message []byte, expiry time.Time) (signature string, err error) {
+	return k.sign(ctx, message, expiry)
+}
+
+func (k *Keyring) sign(ctx context.Context, message []byte, expiry time.Time) (signature string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.primary == nil {
+		return "", ErrKeyringEmpty
+	}
+
+	switch {
+	case expiry.IsZero():
+		k.primary.untracked = true
+
+	case expiry.After(k.primary.signedUntil):
+		k.primary.signedUntil = expiry
+	}
+
+	err = k.primary.key.use(func(key []byte) error {
+		signature = HMACSHA256(key, message)
+		return nil
+	})
+
+	return signature, err
+}
+
+// Verify checks the signature against every key in the keyring, returning the
+// name of the key that produced it.
+func (k *Keyring) Verify(ctx context.Context, message []byte, signature string) (name string, ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", false, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if len(k.keys) == 0 {
+		return "", false, ErrKeyringEmpty
+	}
+
+	for _, entry := range k.keys {
+		err = entry.key.use(func(key []byte) error {
+			ok = Equal(signature, HMACSHA256(key, message))
+			return nil
+		})
+		if err != nil {
+			return "", false, err
+		}
+
+		if ok {
+			return entry.name, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// SignedUntil returns the time at which every signature made by the keyring
+// with the named key will have expired. A zero time is returned if the key
+// hasn't signed anything. Signatures made by other processes aren't tracked.
+//
+// ErrKeyExpiryUntracked is returned if the key has signed a message without an
+// expiry, along with the latest known expiry.
+func (k *Keyring) SignedUntil(name string) (time.Time, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	entry := k.find(name)
+	if entry == nil {
+		return time.Time{}, ErrKeyNotFound
+	}
+
+	if entry.untracked {
+		return entry.signedUntil, ErrKeyExpiryUntracked
+	}
+
+	return entry.signedUntil, nil
+}
+
+// find returns the named key entry, or nil if not found. The caller must hold
+// the lock.
+func (k *Keyring) find(name string) *keyringEntry {
+	for _, entry := range k.keys {
+		if entry.name == name {
+			return entry
+		}
+	}
+
+	return nil
+}
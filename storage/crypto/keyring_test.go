@@ -0,0 +1,277 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crypto
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyring_Add(t *testing.T) {
+	keyring := NewKeyring()
+	defer keyring.Close()
+
+	if err := keyring.AddBase64("key1", "a2V5MQ=="); err != nil {
+		t.Fatalf("AddBase64() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keyName string
+		wantErr error
+	}{
+		{
+			name:    "Should add a new key",
+			keyName: "key2",
+		},
+		{
+			name:    "Should destroy a key with an existing name",
+			keyName: "key1",
+			wantErr: ErrKeyExists,
+		},
+		{
+			name:    "Should destroy a key without a name",
+			keyName: "",
+			wantErr: ErrKeyNameEmpty,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewKey([]byte("secret"))
+			if err != nil {
+				t.Fatalf("NewKey() unexpected error: %v", err)
+			}
+
+			if err = keyring.Add(tt.keyName, key); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Add() error\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+
+			if got, want := key.Destroyed(), tt.wantErr != nil; got != want {
+				t.Errorf("Add() key destroyed\ngot:  = %v\nwant: %v\n", got, want)
+			}
+		})
+	}
+
+	if got, want := keyring.Primary(), "key1"; got != want {
+		t.Errorf("Primary()\ngot:  = %v\nwant: %v\n", got, want)
+	}
+}
+
+func TestKeyring_Verify(t *testing.T) {
+	ctx := context.Background()
+	keyring := NewKeyring()
+	_ = keyring.AddBase64("key1", "a2V5MQ==")
+	_ = keyring.AddBase64("key2", "a2V5Mg==")
+
+	message := []byte("message")
+	key1Signature, _ := keyring.Sign(ctx, message)
+	_ = keyring.SetPrimary("key2")
+	key2Signature, _ := keyring.Sign(ctx, message)
+
+	tests := []struct {
+		name      string
+		signature string
+		wantName  string
+		wantOk    bool
+	}{
+		{
+			name:      "Should match the secondary key",
+			signature: key1Signature,
+			wantName:  "key1",
+			wantOk:    true,
+		},
+		{
+			name:      "Should match the primary key",
+			signature: key2Signature,
+			wantName:  "key2",
+			wantOk:    true,
+		},
+		{
+			name:      "Should not match an unknown signature",
+			signature: "bm9wZQ==",
+			wantName:  "",
+			wantOk:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotName, gotOk, err := keyring.Verify(ctx, message, tt.signature)
+			if err != nil {
+				t.Fatalf("Verify() unexpected error: %v", err)
+			}
+			if gotName != tt.wantName {
+				t.Errorf("Verify() name\ngot:  = %v\nwant: %v\n", gotName, tt.wantName)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("Verify() ok\ngot:  = %v\nwant: %v\n", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	message := []byte("message")
+
+	keyring := NewKeyring()
+	_ = keyring.AddBase64("key1", "a2V5MQ==")
+	_ = keyring.AddBase64("key2", "a2V5Mg==")
+	keyringSignature, _ := keyring.Sign(ctx, message)
+
+	key, _ := NewKey([]byte("key1"))
+	signer, _ := NewKeySigner(key)
+	signerSignature, _ := signer.Sign(ctx, message)
+
+	tests := []struct {
+		name      string
+		signer    Signer
+		signature string
+		wantName  string
+		wantOk    bool
+	}{
+		{
+			name:      "Should return the name of the keyring key that matched",
+			signer:    keyring,
+			signature: keyringSignature,
+			wantName:  "key1",
+			wantOk:    true,
+		},
+		{
+			name:      "Should not match an unknown signature against a keyring",
+			signer:    keyring,
+			signature: "bm9wZQ==",
+			wantName:  "",
+			wantOk:    false,
+		},
+		{
+			name:      "Should match a signer without a key name",
+			signer:    signer,
+			signature: signerSignature,
+			wantName:  "",
+			wantOk:    true,
+		},
+		{
+			name:      "Should not match an unknown signature against a signer",
+			signer:    signer,
+			signature: "bm9wZQ==",
+			wantName:  "",
+			wantOk:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotName, gotOk, err := Verify(ctx, tt.signer, message, tt.signature)
+			if err != nil {
+				t.Fatalf("Verify() unexpected error: %v", err)
+			}
+			if gotName != tt.wantName {
+				t.Errorf("Verify() name\ngot:  = %v\nwant: %v\n", gotName, tt.wantName)
+			}
+			if gotOk != tt.wantOk {
+				t.Errorf("Verify() ok\ngot:  = %v\nwant: %v\n", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestKeyring_SignedUntil(t *testing.T) {
+	ctx := context.Background()
+	message := []byte("message")
+	earlier := time.Date(2021, 12, 12, 10, 0, 0, 0, time.UTC)
+	later := earlier.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		sign    func(keyring *Keyring) error
+		keyName string
+		want    time.Time
+		wantErr error
+	}{
+		{
+			name:    "Should return a zero time for a key which hasn't signed anything",
+			sign:    func(keyring *Keyring) error { return nil },
+			keyName: "key1",
+		},
+		{
+			name: "Should return the latest expiry signed with the key",
+			sign: func(keyring *Keyring) error {
+				if _, err := keyring.SignWithExpiry(ctx, message, later); err != nil {
+					return err
+				}
+
+				_, err := keyring.SignWithExpiry(ctx, message, earlier)
+				return err
+			},
+			keyName: "key1",
+			want:    later,
+		},
+		{
+			name: "Should only track expiries against the key which signed",
+			sign: func(keyring *Keyring) error {
+				_, err := keyring.SignWithExpiry(ctx, message, later)
+				return err
+			},
+			keyName: "key2",
+		},
+		{
+			name: "Should report signing without an expiry as untracked",
+			sign: func(keyring *Keyring) error {
+				if _, err := keyring.SignWithExpiry(ctx, message, earlier); err != nil {
+					return err
+				}
+
+				_, err := keyring.Sign(ctx, message)
+				return err
+			},
+			keyName: "key1",
+			want:    earlier,
+			wantErr: ErrKeyExpiryUntracked,
+		},
+		{
+			name:    "Should error on an unknown key",
+			sign:    func(keyring *Keyring) error { return nil },
+			keyName: "key3",
+			wantErr: ErrKeyNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring := NewKeyring()
+			defer keyring.Close()
+			_ = keyring.AddBase64("key1", "a2V5MQ==")
+			_ = keyring.AddBase64("key2", "a2V5Mg==")
+
+			if err := tt.sign(keyring); err != nil {
+				t.Fatalf("sign() unexpected error: %v", err)
+			}
+
+			got, err := keyring.SignedUntil(tt.keyName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SignedUntil() error\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("SignedUntil()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
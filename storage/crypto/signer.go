@@ -0,0 +1,137 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crypto
+
+import (
+	// Standard Library Imports
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrSignerKeyEmpty = errors.New("signer key must not be empty")
+)
+
+// Signer generates a base64 encoded HMAC-SHA256 signature for a message.
+//
+// Signer enables key custody to be delegated, for example, to an external
+// process, a socket or a HSM, so that the raw key doesn't have to be held in
+// process memory. Implementations must be safe for concurrent use.
+type Signer interface {
+	Sign(ctx context.Context, message []byte) (signature string, err error)
+}
+
+// ExpirySigner is implemented by signers that need to know when a signature
+// stops being valid, for example, to track when a retiring key can be safely
+// rotated.
+type ExpirySigner interface {
+	Signer
+	SignWithExpiry(ctx context.Context, message []byte, expiry time.Time) (signature string, err error)
+}
+
+// SignWithExpiry signs the message with the signer, informing the signer of
+// the signature's expiry if it implements ExpirySigner.
+func SignWithExpiry(ctx context.Context, signer Signer, message []byte, expiry time.Time) (signature string, err error) {
+	if expirySigner, ok := signer.(ExpirySigner); ok {
+		return expirySigner.SignWithExpiry(ctx, message, expiry)
+	}
+
+	return signer.Sign(ctx, message)
+}
+
+// Verifier is implemented by signers which hold more than one key, for
+// example, a Keyring, to verify a signature against every key.
+type Verifier interface {
+	Verify(ctx context.Context, message []byte, signature string) (name string, ok bool, err error)
+}
+
+// Verify reports whether the signature was produced by the signer, checking
+// against every key if the signer implements Verifier. The name of the key
+// that matched is returned for a Verifier, otherwise name is empty.
+func Verify(ctx context.Context, signer Signer, message []byte, signature string) (name string, ok bool, err error) {
+	if verifier, isVerifier := signer.(Verifier); isVerifier {
+		return verifier.Verify(ctx, message, signature)
+	}
+
+	expected, err := signer.Sign(ctx, message)
+	if err != nil {
+		return "", false, err
+	}
+
+	return "", Equal(signature, expected), nil
+}
+
+// Equal reports whether two signatures are equal using a constant time
+// comparison, so as not to leak timing information when verifying.
+func Equal(signature string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) == 1
+}
+
+// Close closes the signer if it implements io.Closer, for example, destroying
+// the key held by a key signer or keyring. Other signers are left as is.
+func Close(signer Signer) error {
+	if closer, ok := signer.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// SignerFunc enables an ordinary function to be used as a Signer.
+type SignerFunc func(ctx context.Context, message []byte) (signature string, err error)
+
+// Sign implements Signer.
+func (f SignerFunc) Sign(ctx context.Context, message []byte) (signature string, err error) {
+	return f(ctx, message)
+}
+
+// NewKeySigner returns the default Signer which signs messages with an
+// in-memory key. The signer shares the key, so destroying the key also
+// disables the signer.
+func NewKeySigner(key *Key) (Signer, error) {
+	if key == nil || key.Destroyed() {
+		return nil, ErrSignerKeyEmpty
+	}
+
+	return &keySigner{key: key}, nil
+}
+
+type keySigner struct {
+	key *Key
+}
+
+// Sign implements Signer.
+func (s *keySigner) Sign(ctx context.Context, message []byte) (signature string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	err = s.key.use(func(key []byte) error {
+		signature = HMACSHA256(key, message)
+		return nil
+	})
+
+	return signature, err
+}
+
+// Close implements io.Closer, destroying the signer's key.
+func (s *keySigner) Close() error {
+	return s.key.Close()
+}
@@ -0,0 +1,455 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package endpoints provides the service endpoints for a storage account,
+// supporting sovereign clouds, read-access geo-redundant secondary endpoints,
+// private endpoints and custom domains, as well as building signed resource
+// URLs.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/storage/common/storage-account-overview#storage-account-endpoints
+package endpoints
+
+import (
+	// Standard Library Imports
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/services"
+)
+
+// Service specifies a storage service endpoint.
+type Service string
+
+// String implements Stringer.
+func (s Service) String() string {
+	return string(s)
+}
+
+const (
+	Blob  Service = "blob"
+	Queue Service = "queue"
+	Table Service = "table"
+	File  Service = "file"
+	// DFS is the Data Lake Storage Gen2 endpoint.
+	DFS Service = "dfs"
+	// Web is the static website endpoint.
+	Web Service = "web"
+)
+
+const (
+	secondarySuffix   = "-secondary"
+	privateLinkPrefix = "privatelink"
+)
+
+// Well-known development storage (Azurite/storage emulator) settings.
+// Refer: https://docs.microsoft.com/en-us/azure/storage/common/storage-use-azurite#connection-strings
+const (
+	DevelopmentStorageAccountName = "devstoreaccount1"
+	DevelopmentStorageHost        = "127.0.0.1"
+)
+
+// developmentStoragePorts maps each service to the port development storage
+// serves it on by default.
+var developmentStoragePorts = map[Service]int{
+	Blob:  10000,
+	Queue: 10001,
+	Table: 10002,
+}
+
+// Suffix specifies the DNS suffix of the cloud the storage account lives in.
+type Suffix string
+
+// String implements Stringer.
+func (s Suffix) String() string {
+	return string(s)
+}
+
+const (
+	AzurePublic       Suffix = "core.windows.net"
+	AzureChina        Suffix = "core.chinacloudapi.cn"
+	AzureUSGovernment Suffix = "core.usgovcloudapi.net"
+)
+
+var (
+	ErrAccountNameEmpty = errors.New("storage account name must not be empty")
+	ErrInvalidProtocol  = errors.New("invalid protocol, must be http or https")
+	ErrInvalidSuffix    = errors.New("invalid endpoint suffix")
+	ErrInvalidEndpoint  = errors.New("invalid endpoint, must be an absolute http or https URL")
+	ErrUnknownService   = errors.New("unknown storage service")
+	ErrNoSecondary      = errors.New("secondary endpoints are not available for custom endpoints")
+	ErrInvalidToken     = errors.New("invalid SAS token, must be a URL encoded query string")
+	ErrInvalidWebZone   = errors.New("invalid static website zone, must be in the form z[number]")
+	ErrInvalidHost      = errors.New("invalid development storage host")
+)
+
+// Endpoints builds the service endpoints for a storage account.
+type Endpoints struct {
+	AccountName string
+	// Protocol is either https (default) or http.
+	Protocol string
+	// Suffix is the cloud's endpoint suffix. Defaults to AzurePublic.
+	Suffix Suffix
+	// Custom overrides the endpoint for a service, for example, with a custom
+	// domain, or an explicit endpoint from a connection string.
+	Custom map[Service]*url.URL
+	// PrivateLink builds endpoints using the privatelink subdomain, for
+	// example, account.privatelink.blob.core.windows.net.
+	PrivateLink bool
+	// WebZone is the zone of the static website endpoint, for example, "z13"
+	// in account.z13.web.core.windows.net.
+	WebZone string
+	// DevelopmentStorageHost is set when targeting development storage, such
+	// as Azurite, which uses path-style URLs, for example,
+	// http://127.0.0.1:10000/devstoreaccount1.
+	DevelopmentStorageHost string
+}
+
+// NewDevelopmentStorage returns the endpoints for the well-known development
+// storage account, served over HTTP from 127.0.0.1 on the default ports.
+func NewDevelopmentStorage(opts ...Option) (*Endpoints, error) {
+	opts = append([]Option{WithDevelopmentStorage(DevelopmentStorageHost)}, opts...)
+
+	return New(DevelopmentStorageAccountName, opts...)
+}
+
+// Option configures Endpoints.
+type Option func(e *Endpoints) error
+
+// New returns the endpoints for a storage account in the Azure public cloud,
+// unless configured otherwise.
+func New(accountName string, opts ...Option) (*Endpoints, error) {
+	if strings.TrimSpace(accountName) == "" {
+		return nil, ErrAccountNameEmpty
+	}
+
+	e := &Endpoints{
+		AccountName: strings.TrimSpace(accountName),
+		Protocol:    "https",
+		Suffix:      AzurePublic,
+		Custom:      map[Service]*url.URL{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// WithProtocol sets the protocol used by the endpoints, either https or http.
+func WithProtocol(protocol string) Option {
+	return func(e *Endpoints) error {
+		protocol = strings.ToLower(strings.TrimSpace(protocol))
+		if protocol != "http" && protocol != "https" {
+			return ErrInvalidProtocol
+		}
+
+		e.Protocol = protocol
+
+		return nil
+	}
+}
+
+// WithSuffix sets the endpoint suffix, for example, AzureChina, or a custom
+// suffix for an Azure Stack deployment.
+func WithSuffix(suffix Suffix) Option {
+	return func(e *Endpoints) error {
+		suffix = Suffix(strings.Trim(strings.TrimSpace(suffix.String()), "."))
+		if suffix == "" || strings.ContainsAny(suffix.String(), "/:") {
+			return ErrInvalidSuffix
+		}
+
+		e.Suffix = suffix
+
+		return nil
+	}
+}
+
+// WithCustomEndpoint overrides the endpoint for a service, for example, with a
+// custom domain such as "https://assets.example.com".
+func WithCustomEndpoint(service Service, endpoint string) Option {
+	return func(e *Endpoints) error {
+		u, err := url.Parse(strings.TrimSpace(endpoint))
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return ErrInvalidEndpoint
+		}
+
+		e.Custom[service] = u
+
+		return nil
+	}
+}
+
+// WithPrivateLink builds endpoints using the privatelink subdomain, for
+// example, account.privatelink.blob.core.windows.net, to target a private
+// endpoint directly.
+func WithPrivateLink() Option {
+	return func(e *Endpoints) error {
+		e.PrivateLink = true
+
+		return nil
+	}
+}
+
+// WithDevelopmentStorage targets development storage, such as Azurite, served
+// from the given host, for example, "127.0.0.1", or a docker compose service
+// name. Endpoints use path-style URLs on the default development storage
+// ports, over HTTP unless followed by WithProtocol.
+func WithDevelopmentStorage(host string) Option {
+	return func(e *Endpoints) error {
+		host = strings.TrimSpace(host)
+		if host == "" || strings.ContainsAny(host, "/?#@") {
+			return ErrInvalidHost
+		}
+
+		e.DevelopmentStorageHost = host
+		e.Protocol = "http"
+
+		return nil
+	}
+}
+
+// IsDevelopmentStorage reports whether the endpoints target development
+// storage.
+func (e *Endpoints) IsDevelopmentStorage() bool {
+	return e.DevelopmentStorageHost != ""
+}
+
+// WithWebZone sets the zone of the static website endpoint, for example,
+// "z13" for account.z13.web.core.windows.net.
+func WithWebZone(zone string) Option {
+	return func(e *Endpoints) error {
+		zone = strings.ToLower(strings.TrimSpace(zone))
+		if len(zone) < 2 || zone[0] != 'z' || strings.Trim(zone[1:], "0123456789") != "" {
+			return ErrInvalidWebZone
+		}
+
+		e.WebZone = zone
+
+		return nil
+	}
+}
+
+// URL returns the primary endpoint for the given service.
+func (e *Endpoints) URL(service Service) *url.URL {
+	if custom, ok := e.Custom[service]; ok {
+		u := *custom
+		if u.Path == "" {
+			u.Path = "/"
+		}
+
+		return &u
+	}
+
+	return e.build(e.AccountName, service)
+}
+
+// SecondaryURL returns the read-access geo-redundant secondary endpoint for
+// the given service, for example, account-secondary.blob.core.windows.net.
+func (e *Endpoints) SecondaryURL(service Service) (*url.URL, error) {
+	if _, ok := e.Custom[service]; ok {
+		return nil, ErrNoSecondary
+	}
+
+	return e.build(e.AccountName+secondarySuffix, service), nil
+}
+
+// build returns the endpoint for a service using host-style addressing.
+func (e *Endpoints) build(account string, service Service) *url.URL {
+	if e.IsDevelopmentStorage() {
+		return e.buildPathStyle(account, service)
+	}
+
+	labels := []string{account}
+	if service == Web && e.WebZone != "" {
+		labels = append(labels, e.WebZone)
+	}
+	if e.PrivateLink {
+		labels = append(labels, privateLinkPrefix)
+	}
+	labels = append(labels, service.String(), e.Suffix.String())
+
+	return &url.URL{
+		Scheme: e.Protocol,
+		Host:   strings.Join(labels, "."),
+		Path:   "/",
+	}
+}
+
+// buildPathStyle returns the endpoint for a service using path-style
+// addressing, as used by development storage.
+func (e *Endpoints) buildPathStyle(account string, service Service) *url.URL {
+	host := e.DevelopmentStorageHost
+	if port, ok := developmentStoragePorts[service]; ok {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+
+	return &url.URL{
+		Scheme: e.Protocol,
+		Host:   host,
+		Path:   "/" + account + "/",
+	}
+}
+
+// ResourcePath returns the URL decoded path of a resource URL relative to the
+// storage account. For path-style URLs, the leading account name segment is
+// removed, for example, both https://account.blob.core.windows.net/c/b and
+// http://127.0.0.1:10000/account/c/b return /c/b.
+func (e *Endpoints) ResourcePath(u *url.URL) string {
+	path := u.Path
+	if e.IsDevelopmentStorage() {
+		path = strings.TrimPrefix(path, "/"+e.AccountName)
+	}
+
+	if path == "" {
+		return "/"
+	}
+
+	return path
+}
+
+// CanonicalizedResource returns the canonicalized resource of a request URL,
+// excluding query parameters, as required to sign a request with Shared Key.
+//
+// The format is /{account}{escaped URL path}. As path-style URLs already
+// contain the account name, the account name appears twice for development
+// storage, for example, /devstoreaccount1/devstoreaccount1/container.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#shared-key-format-for-2009-09-19-and-later
+func (e *Endpoints) CanonicalizedResource(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	return "/" + e.AccountName + path
+}
+
+// SASCanonicalizedResource returns the canonicalized resource of a resource
+// URL, as required to sign a service SAS, in the form
+// /{service}/{account}{URL decoded path relative to the account}.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-service-sas#specifying-the-signed-resource-blob-storage-only
+func (e *Endpoints) SASCanonicalizedResource(service Service, u *url.URL) string {
+	return "/" + service.String() + "/" + e.AccountName + strings.TrimSuffix(e.ResourcePath(u), "/")
+}
+
+// ContainerURL returns the URL of a blob container.
+func (e *Endpoints) ContainerURL(container string) *url.URL {
+	return e.ResourceURL(Blob, container)
+}
+
+// BlobURL returns the URL of a blob. Slashes in the blob name are treated as
+// virtual directory separators.
+func (e *Endpoints) BlobURL(container string, blob string) *url.URL {
+	return e.ResourceURL(Blob, container, blob)
+}
+
+// DirectoryURL returns the Data Lake Storage Gen2 URL of a directory within a
+// file system.
+func (e *Endpoints) DirectoryURL(fileSystem string, directory string) *url.URL {
+	return e.ResourceURL(DFS, fileSystem, directory)
+}
+
+// ShareFileURL returns the URL of a file, or directory, within a file share.
+func (e *Endpoints) ShareFileURL(share string, path string) *url.URL {
+	return e.ResourceURL(File, share, path)
+}
+
+// QueueURL returns the URL of a queue.
+func (e *Endpoints) QueueURL(queue string) *url.URL {
+	return e.ResourceURL(Queue, queue)
+}
+
+// TableURL returns the URL of a table.
+func (e *Endpoints) TableURL(table string) *url.URL {
+	return e.ResourceURL(Table, table)
+}
+
+// ResourceURL returns the URL of a resource on the given service, joining the
+// provided path segments. Each segment is escaped, while slashes within a
+// segment are kept as path separators.
+func (e *Endpoints) ResourceURL(service Service, segments ...string) *url.URL {
+	u := e.URL(service)
+	basePath := strings.TrimSuffix(u.Path, "/")
+	baseRawPath := strings.TrimSuffix(u.EscapedPath(), "/")
+
+	var paths, rawPaths []string
+	for _, segment := range segments {
+		for _, part := range strings.Split(strings.Trim(segment, "/"), "/") {
+			if part == "" {
+				continue
+			}
+
+			paths = append(paths, part)
+			rawPaths = append(rawPaths, url.PathEscape(part))
+		}
+	}
+
+	u.Path = basePath + "/" + strings.Join(paths, "/")
+	u.RawPath = baseRawPath + "/" + strings.Join(rawPaths, "/")
+
+	return u
+}
+
+// Sign returns a copy of the URL with the SAS token merged into any existing
+// query parameters. SAS parameters already present on the URL are replaced.
+func Sign(u *url.URL, token string) (*url.URL, error) {
+	sas, err := url.ParseQuery(strings.TrimPrefix(token, "?"))
+	if err != nil || len(sas) == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, ErrInvalidEndpoint
+	}
+
+	for key, values := range sas {
+		query[key] = values
+	}
+
+	signed := *u
+	signed.RawQuery = query.Encode()
+
+	return &signed, nil
+}
+
+// FromSignedService returns the endpoint service for a signed service.
+func FromSignedService(service services.SignedService) (Service, error) {
+	switch service {
+	case services.Blob:
+		return Blob, nil
+
+	case services.Queue:
+		return Queue, nil
+
+	case services.Table:
+		return Table, nil
+
+	case services.File:
+		return File, nil
+
+	default:
+		return "", ErrUnknownService
+	}
+}
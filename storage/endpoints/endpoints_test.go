@@ -0,0 +1,217 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package endpoints
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestEndpoints_URL(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		service Service
+		want    string
+	}{
+		{
+			name:    "Should build a public blob endpoint",
+			service: Blob,
+			want:    "https://sassy.blob.core.windows.net/",
+		},
+		{
+			name:    "Should build a China dfs endpoint",
+			opts:    []Option{WithSuffix(AzureChina)},
+			service: DFS,
+			want:    "https://sassy.dfs.core.chinacloudapi.cn/",
+		},
+		{
+			name:    "Should build a US Government queue endpoint",
+			opts:    []Option{WithSuffix(AzureUSGovernment)},
+			service: Queue,
+			want:    "https://sassy.queue.core.usgovcloudapi.net/",
+		},
+		{
+			name:    "Should build a private endpoint",
+			opts:    []Option{WithPrivateLink()},
+			service: Table,
+			want:    "https://sassy.privatelink.table.core.windows.net/",
+		},
+		{
+			name:    "Should build a zoned static website endpoint",
+			opts:    []Option{WithWebZone("z13")},
+			service: Web,
+			want:    "https://sassy.z13.web.core.windows.net/",
+		},
+		{
+			name:    "Should prefer a custom endpoint",
+			opts:    []Option{WithCustomEndpoint(File, "https://files.example.com")},
+			service: File,
+			want:    "https://files.example.com/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New("sassy", tt.opts...)
+			if err != nil {
+				t.Fatalf("New() unexpected error: %v", err)
+			}
+
+			if got := e.URL(tt.service).String(); got != tt.want {
+				t.Errorf("URL()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpoints_SecondaryURL(t *testing.T) {
+	e, _ := New("sassy", WithCustomEndpoint(Blob, "https://assets.example.com"))
+
+	got, err := e.SecondaryURL(Queue)
+	if err != nil {
+		t.Fatalf("SecondaryURL() unexpected error: %v", err)
+	}
+	if want := "https://sassy-secondary.queue.core.windows.net/"; got.String() != want {
+		t.Errorf("SecondaryURL()\ngot:  = %v\nwant: %v\n", got, want)
+	}
+
+	if _, err = e.SecondaryURL(Blob); err != ErrNoSecondary {
+		t.Errorf("SecondaryURL() error\ngot:  = %v\nwant: %v\n", err, ErrNoSecondary)
+	}
+}
+
+func TestEndpoints_ResourceURL(t *testing.T) {
+	e, _ := New("sassy")
+	custom, _ := New("sassy", WithCustomEndpoint(Blob, "https://example.com/base/"))
+
+	tests := []struct {
+		name string
+		got  *url.URL
+		want string
+	}{
+		{
+			name: "Should build a container URL",
+			got:  e.ContainerURL("container"),
+			want: "https://sassy.blob.core.windows.net/container",
+		},
+		{
+			name: "Should keep virtual directories in a blob name",
+			got:  e.BlobURL("container", "path/to/blob.txt"),
+			want: "https://sassy.blob.core.windows.net/container/path/to/blob.txt",
+		},
+		{
+			name: "Should escape special characters in a blob name",
+			got:  e.BlobURL("container", "my blob?#%.txt"),
+			want: "https://sassy.blob.core.windows.net/container/my%20blob%3F%23%25.txt",
+		},
+		{
+			name: "Should build a directory URL",
+			got:  e.DirectoryURL("filesystem", "/dir/sub dir/"),
+			want: "https://sassy.dfs.core.windows.net/filesystem/dir/sub%20dir",
+		},
+		{
+			name: "Should join onto a custom endpoint path",
+			got:  custom.BlobURL("container", "blob"),
+			want: "https://example.com/base/container/blob",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.String(); got != tt.want {
+				t.Errorf("ResourceURL()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	u, _ := url.Parse("https://sassy.blob.core.windows.net/container?restype=container&comp=list&sig=stale")
+
+	got, err := Sign(u, "?sv=2020-10-02&sig=c2ln%2Bbg%3D%3D")
+	if err != nil {
+		t.Fatalf("Sign() unexpected error: %v", err)
+	}
+
+	want := "https://sassy.blob.core.windows.net/container?comp=list&restype=container&sig=c2ln%2Bbg%3D%3D&sv=2020-10-02"
+	if got.String() != want {
+		t.Errorf("Sign()\ngot:  = %v\nwant: %v\n", got, want)
+	}
+
+	if _, err = Sign(u, ""); err != ErrInvalidToken {
+		t.Errorf("Sign() error\ngot:  = %v\nwant: %v\n", err, ErrInvalidToken)
+	}
+}
+
+func TestEndpoints_DevelopmentStorage(t *testing.T) {
+	e, err := NewDevelopmentStorage()
+	if err != nil {
+		t.Fatalf("NewDevelopmentStorage() unexpected error: %v", err)
+	}
+
+	blob := e.BlobURL("container", "path/to/blob.txt")
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "Should build a path-style blob endpoint",
+			got:  e.URL(Blob).String(),
+			want: "http://127.0.0.1:10000/devstoreaccount1/",
+		},
+		{
+			name: "Should build a path-style queue endpoint",
+			got:  e.URL(Queue).String(),
+			want: "http://127.0.0.1:10001/devstoreaccount1/",
+		},
+		{
+			name: "Should build a path-style table endpoint",
+			got:  e.URL(Table).String(),
+			want: "http://127.0.0.1:10002/devstoreaccount1/",
+		},
+		{
+			name: "Should build a path-style blob URL",
+			got:  blob.String(),
+			want: "http://127.0.0.1:10000/devstoreaccount1/container/path/to/blob.txt",
+		},
+		{
+			name: "Should return the path relative to the account",
+			got:  e.ResourcePath(blob),
+			want: "/container/path/to/blob.txt",
+		},
+		{
+			name: "Should repeat the account in the Shared Key canonicalized resource",
+			got:  e.CanonicalizedResource(e.ContainerURL("container")),
+			want: "/devstoreaccount1/devstoreaccount1/container",
+		},
+		{
+			name: "Should not repeat the account in the SAS canonicalized resource",
+			got:  e.SASCanonicalizedResource(Blob, blob),
+			want: "/blob/devstoreaccount1/container/path/to/blob.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got:  = %v\nwant: %v\n", tt.got, tt.want)
+			}
+		})
+	}
+}
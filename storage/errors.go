@@ -23,8 +23,24 @@ import (
 
 var (
 	ErrDecodingStorageAccountKey = errors.New("error decoding storage account key, must be base64 encoded")
+	ErrSignerRequired            = errors.New("a signer must be provided to sign the SAS token")
 	ErrInvalidVersion            = errors.New("error parsing signed version")
 	ErrInvalidStartDateFormat    = errors.New("invalid date format provided for signed start, must be ISO 8601 formatted date string")
 	ErrInvalidExpiryDateFormat   = errors.New("invalid date format provided for signed expiry, must be ISO 8601 formatted date string")
-	ErrInvalidIPv4Format         = errors.New("invalid IPv4 address, or IPv4 address range")
+	ErrInvalidIPv4Format         = errors.New("invalid IPv4 address, IPv4 address range or IPv4 CIDR")
+	ErrIPv6NotSupported          = errors.New("IPv6 addresses are not supported by Azure SAS")
+	ErrInvalidClockSkew          = errors.New("invalid clock skew provided, must not be negative")
+	ErrStartAfterExpiry          = errors.New("signed start must be before signed expiry")
+	ErrExpiryInPast              = errors.New("signed expiry must be in the future")
+	ErrInvalidTimeZone           = errors.New("invalid timezone, must be an IANA timezone name")
+
+	ErrInvalidEndpointsProtocol     = errors.New("invalid default endpoints protocol, must be http or https")
+	ErrInvalidEndpoint              = errors.New("invalid endpoint, must be an absolute http or https URL")
+	ErrInvalidSharedAccessSignature = errors.New("invalid shared access signature, must be a signed SAS token")
+	ErrAccountKeyAndSAS             = errors.New("account key and shared access signature can not both be specified")
+	ErrDevelopmentStorageOnly       = errors.New("can only be specified when using development storage")
+	ErrDevelopmentStorageExclusive  = errors.New("can not be specified when using development storage")
+
+	ErrEndpointsRequired = errors.New("storage service endpoints must be provided")
+	ErrNoSignedServices  = errors.New("at least one signed service is required to build a connection string")
 )
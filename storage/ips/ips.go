@@ -18,9 +18,10 @@ package ips
 
 import (
 	// Standard Library Imports
-	"bytes"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"net/url"
 	"strings"
 )
@@ -28,6 +29,15 @@ import (
 const (
 	paramKey         = "sip"
 	ipRangeSeparator = "-"
+	cidrSeparator    = "/"
+)
+
+var (
+	ErrInvalidIP         = errors.New("invalid IPv4 address, IPv4 address range or IPv4 CIDR")
+	ErrInvalidRange      = errors.New("invalid IPv4 address range, range start must not be greater than range end")
+	ErrIPv6NotSupported  = errors.New("IPv6 addresses are not supported by Azure SAS, only IPv4 addresses can be signed")
+	ErrPrivateRange      = errors.New("IP range includes private, carrier-grade NAT, loopback or link-local addresses, which are not seen as a source address by Azure Storage")
+	ErrWholeAddressSpace = errors.New("IP range covers the whole IPv4 address space, which does not restrict access")
 )
 
 type SignedIP string
@@ -54,57 +64,241 @@ func (s SignedIP) GetParam() (signedIPs string) {
 	return
 }
 
+// Range returns the first and last IPv4 address covered by the signed IP. A
+// single IP returns the same address for both.
+func (s SignedIP) Range() (start netip.Addr, end netip.Addr, ok bool) {
+	splitIPs := strings.Split(s.String(), ipRangeSeparator)
+	switch len(splitIPs) {
+	case 1:
+		ip, err := parseIPv4(splitIPs[0])
+		return ip, ip, err == nil
+
+	case 2:
+		rangeStart, startErr := parseIPv4(splitIPs[0])
+		rangeEnd, endErr := parseIPv4(splitIPs[1])
+		return rangeStart, rangeEnd, startErr == nil && endErr == nil
+
+	default:
+		return
+	}
+}
+
+// Contains reports whether the given IP is permitted by the signed IP.
+func (s SignedIP) Contains(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+
+	return s.ContainsAddr(addr)
+}
+
+// ContainsAddr reports whether the given address is permitted by the signed
+// IP.
+func (s SignedIP) ContainsAddr(addr netip.Addr) bool {
+	start, end, ok := s.Range()
+	if !ok {
+		return false
+	}
+
+	addr = addr.Unmap()
+	return addr.Is4() && start.Compare(addr) <= 0 && addr.Compare(end) <= 0
+}
+
+// Warnings returns any concerns with the signed IP which, while valid, are
+// unlikely to be what was intended.
+func (s SignedIP) Warnings() (warnings []error) {
+	start, end, ok := s.Range()
+	if !ok {
+		return nil
+	}
+
+	for _, block := range privateBlocks {
+		if overlaps(start, end, block) {
+			warnings = append(warnings, ErrPrivateRange)
+			break
+		}
+	}
+
+	if start == netip.IPv4Unspecified() && end == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+		warnings = append(warnings, ErrWholeAddressSpace)
+	}
+
+	return warnings
+}
+
+// Parse returns a signed IP from either a single IPv4 address, a dash
+// separated IPv4 address range, for example, "10.1.0.0-10.1.3.255", or an IPv4
+// CIDR, for example, "10.1.0.0/22".
 func Parse(ips string) (sip SignedIP, ok bool) {
+	sip, err := FromString(ips)
+	return sip, err == nil
+}
+
+// FromString returns a signed IP in the same way as Parse, but reports why the
+// provided IPs are invalid.
+func FromString(ips string) (sip SignedIP, err error) {
+	ips = strings.TrimSpace(ips)
+	if strings.Contains(ips, cidrSeparator) {
+		prefix, err := netip.ParsePrefix(ips)
+		if err != nil {
+			if isIPv6(strings.Split(ips, cidrSeparator)[0]) {
+				return "", ErrIPv6NotSupported
+			}
+
+			return "", ErrInvalidIP
+		}
+
+		return FromPrefix(prefix)
+	}
+
 	// Attempt to separate a provided IP range.
-	splitIPs := strings.Split(strings.TrimSpace(ips), ipRangeSeparator)
+	splitIPs := strings.Split(ips, ipRangeSeparator)
 	switch len(splitIPs) {
 	case 1:
 		// Single IP
-		ip := parseIPv4(splitIPs[0])
-		if ip == nil {
-			// Unable to parse IPv4 successfully, invalid IP.
-			return
+		ip, err := parseIPv4(splitIPs[0])
+		if err != nil {
+			return "", err
 		}
 
-		sip = SignedIP(ip.String())
+		return SignedIP(ip.String()), nil
 
 	case 2:
 		// IP Range
-		rangeStart := parseIPv4(splitIPs[0])
-		rangeEnd := parseIPv4(splitIPs[1])
-
-		if rangeStart == nil || rangeEnd == nil {
-			// One of the IPs have not been parsed successfully, invalid IP
-			// range.
-			return
+		rangeStart, err := parseIPv4(splitIPs[0])
+		if err != nil {
+			return "", err
 		}
 
-		if bytes.Compare(rangeStart, rangeEnd) == 1 {
-			// range start is greater than range end, invalid IP range.
-			return
+		rangeEnd, err := parseIPv4(splitIPs[1])
+		if err != nil {
+			return "", err
 		}
 
-		sip = SignedIP(fmt.Sprintf(
-			"%s-%s",
-			rangeStart.String(),
-			rangeEnd.String(),
-		))
+		return FromRange(rangeStart, rangeEnd)
 
 	default:
 		// Multiple range separators provided, invalid IP range.
-		return
+		return "", ErrInvalidIP
 	}
+}
 
-	return sip, true
+// FromRange returns a signed IP covering the IPv4 addresses from start to end
+// inclusive.
+func FromRange(start netip.Addr, end netip.Addr) (sip SignedIP, err error) {
+	start, end = start.Unmap(), end.Unmap()
+	if start.Is6() || end.Is6() {
+		return "", ErrIPv6NotSupported
+	}
+
+	if !start.Is4() || !end.Is4() {
+		return "", ErrInvalidIP
+	}
+
+	if start.Compare(end) == 1 {
+		// range start is greater than range end, invalid IP range.
+		return "", ErrInvalidRange
+	}
+
+	return SignedIP(fmt.Sprintf(
+		"%s-%s",
+		start.String(),
+		end.String(),
+	)), nil
 }
 
-// parseIPv4 checks parses an IPv4 address, returns nil if invalid.
-func parseIPv4(ip string) net.IP {
-	if parsedIP := net.ParseIP(strings.TrimSpace(ip)); parsedIP != nil {
-		if ipv4 := parsedIP.To4(); ipv4 != nil {
-			return ipv4
-		}
+// FromPrefix returns a signed IP covering every IPv4 address in the prefix. A
+// /32 prefix returns a single IP.
+func FromPrefix(prefix netip.Prefix) (sip SignedIP, err error) {
+	if !prefix.IsValid() {
+		return "", ErrInvalidIP
+	}
+
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+
+	if !addr.Is4() {
+		return "", ErrIPv6NotSupported
+	}
+
+	start := netip.PrefixFrom(addr, bits).Masked().Addr()
+	if bits == 32 {
+		return SignedIP(start.String()), nil
+	}
+
+	end := start.As4()
+	hostBits := uint32(1)<<(32-bits) - 1
+	for i := range end {
+		end[i] |= byte(hostBits >> (8 * (3 - i)))
+	}
+
+	return FromRange(start, netip.AddrFrom4(end))
+}
+
+// FromIPNet returns a signed IP covering every IPv4 address in the network.
+func FromIPNet(ipNet *net.IPNet) (sip SignedIP, err error) {
+	if ipNet == nil {
+		return "", ErrInvalidIP
 	}
 
-	return nil
+	addr, ok := netip.AddrFromSlice(ipNet.IP)
+	if !ok {
+		return "", ErrInvalidIP
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if bits == 0 {
+		// Non-canonical mask.
+		return "", ErrInvalidIP
+	}
+
+	if bits == 32 {
+		// Account for IPv4 addresses stored in their 16 byte form.
+		addr = addr.Unmap()
+	}
+
+	return FromPrefix(netip.PrefixFrom(addr, ones))
+}
+
+// parseIPv4 parses an IPv4 address, reporting IPv6 addresses as unsupported.
+func parseIPv4(ip string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return netip.Addr{}, ErrInvalidIP
+	}
+
+	addr = addr.Unmap()
+	if !addr.Is4() {
+		return netip.Addr{}, ErrIPv6NotSupported
+	}
+
+	return addr, nil
+}
+
+// isIPv6 reports whether the given string is an IPv6 address.
+func isIPv6(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	return err == nil && !addr.Unmap().Is4()
+}
+
+// privateBlocks contains the IPv4 address blocks which are never seen as a
+// source address by Azure Storage over the public internet.
+var privateBlocks = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+}
+
+// overlaps reports whether the range from start to end overlaps the block.
+func overlaps(start netip.Addr, end netip.Addr, block netip.Prefix) bool {
+	blockRange, _ := FromPrefix(block)
+	blockStart, blockEnd, _ := blockRange.Range()
+
+	return start.Compare(blockEnd) <= 0 && blockStart.Compare(end) <= 0
 }
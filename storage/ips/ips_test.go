@@ -17,6 +17,9 @@
 package ips
 
 import (
+	"net"
+	"net/netip"
+	"reflect"
 	"testing"
 )
 
@@ -228,6 +231,56 @@ func TestParse(t *testing.T) {
 			wantSip: "",
 			wantOk:  false,
 		},
+
+		// CIDR testing
+		{
+			name: "Should allow an IPv4 CIDR",
+			args: args{
+				ips: "10.1.0.0/22",
+			},
+			wantSip: "10.1.0.0-10.1.3.255",
+			wantOk:  true,
+		},
+		{
+			name: "Should mask host bits in an IPv4 CIDR",
+			args: args{
+				ips: "10.1.2.3/22",
+			},
+			wantSip: "10.1.0.0-10.1.3.255",
+			wantOk:  true,
+		},
+		{
+			name: "Should allow a /32 IPv4 CIDR as a single IP",
+			args: args{
+				ips: "1.1.1.1/32",
+			},
+			wantSip: "1.1.1.1",
+			wantOk:  true,
+		},
+		{
+			name: "Should allow a /0 IPv4 CIDR",
+			args: args{
+				ips: "0.0.0.0/0",
+			},
+			wantSip: "0.0.0.0-255.255.255.255",
+			wantOk:  true,
+		},
+		{
+			name: "Should not allow an IPv4 CIDR with an invalid prefix length",
+			args: args{
+				ips: "10.1.0.0/33",
+			},
+			wantSip: "",
+			wantOk:  false,
+		},
+		{
+			name: "Should not allow an IPv6 CIDR",
+			args: args{
+				ips: "2001:db8::/32",
+			},
+			wantSip: "",
+			wantOk:  false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -242,3 +295,206 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestFromString(t *testing.T) {
+	tests := []struct {
+		name    string
+		ips     string
+		wantErr error
+	}{
+		{
+			name:    "Should report an invalid IPv4 address",
+			ips:     "1.1.1.256",
+			wantErr: ErrInvalidIP,
+		},
+		{
+			name:    "Should report an IPv6 address",
+			ips:     "2001:db8::1",
+			wantErr: ErrIPv6NotSupported,
+		},
+		{
+			name:    "Should report an IPv6 address range",
+			ips:     "2001:db8::1-2001:db8::2",
+			wantErr: ErrIPv6NotSupported,
+		},
+		{
+			name:    "Should report an IPv6 CIDR",
+			ips:     "2001:db8::/32",
+			wantErr: ErrIPv6NotSupported,
+		},
+		{
+			name:    "Should report a reversed range",
+			ips:     "2.2.2.2-1.1.1.1",
+			wantErr: ErrInvalidRange,
+		},
+		{
+			name:    "Should allow an IPv4-mapped IPv6 address",
+			ips:     "::ffff:1.1.1.1",
+			wantErr: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromString(tt.ips); err != tt.wantErr {
+				t.Errorf("FromString() error\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFromIPNet(t *testing.T) {
+	_, ipv4Net, _ := net.ParseCIDR("192.0.2.0/24")
+	_, ipv6Net, _ := net.ParseCIDR("2001:db8::/32")
+	ipv4Net16Byte := &net.IPNet{IP: ipv4Net.IP.To16(), Mask: ipv4Net.Mask}
+
+	tests := []struct {
+		name    string
+		ipNet   *net.IPNet
+		wantSip SignedIP
+		wantErr error
+	}{
+		{
+			name:    "Should convert an IPv4 network",
+			ipNet:   ipv4Net,
+			wantSip: "192.0.2.0-192.0.2.255",
+		},
+		{
+			name:    "Should convert an IPv4 network stored as 16 bytes",
+			ipNet:   ipv4Net16Byte,
+			wantSip: "192.0.2.0-192.0.2.255",
+		},
+		{
+			name:    "Should report an IPv6 network",
+			ipNet:   ipv6Net,
+			wantErr: ErrIPv6NotSupported,
+		},
+		{
+			name:    "Should report a nil network",
+			ipNet:   nil,
+			wantErr: ErrInvalidIP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSip, err := FromIPNet(tt.ipNet)
+			if gotSip != tt.wantSip {
+				t.Errorf("FromIPNet() sip\ngot:  = %v\nwant: %v\n", gotSip, tt.wantSip)
+			}
+			if err != tt.wantErr {
+				t.Errorf("FromIPNet() error\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignedIP_Contains(t *testing.T) {
+	tests := []struct {
+		name string
+		sip  SignedIP
+		ip   string
+		want bool
+	}{
+		{
+			name: "Should contain a matching single IP",
+			sip:  "1.1.1.1",
+			ip:   "1.1.1.1",
+			want: true,
+		},
+		{
+			name: "Should not contain a different single IP",
+			sip:  "1.1.1.1",
+			ip:   "1.1.1.2",
+			want: false,
+		},
+		{
+			name: "Should contain the start of a range",
+			sip:  "10.1.0.0-10.1.3.255",
+			ip:   "10.1.0.0",
+			want: true,
+		},
+		{
+			name: "Should contain the end of a range",
+			sip:  "10.1.0.0-10.1.3.255",
+			ip:   "10.1.3.255",
+			want: true,
+		},
+		{
+			name: "Should not contain an IP after a range",
+			sip:  "10.1.0.0-10.1.3.255",
+			ip:   "10.1.4.0",
+			want: false,
+		},
+		{
+			name: "Should not contain an IPv6 address",
+			sip:  "0.0.0.0-255.255.255.255",
+			ip:   "2001:db8::1",
+			want: false,
+		},
+		{
+			name: "Should not contain anything when empty",
+			sip:  "",
+			ip:   "1.1.1.1",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sip.Contains(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("Contains()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+			if got := tt.sip.ContainsAddr(netip.MustParseAddr(tt.ip)); got != tt.want {
+				t.Errorf("ContainsAddr()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedIP_Warnings(t *testing.T) {
+	tests := []struct {
+		name string
+		sip  SignedIP
+		want []error
+	}{
+		{
+			name: "Should not warn for a public IP",
+			sip:  "1.1.1.1",
+			want: nil,
+		},
+		{
+			name: "Should warn for a private IP",
+			sip:  "192.168.1.1",
+			want: []error{ErrPrivateRange},
+		},
+		{
+			name: "Should warn for a carrier-grade NAT shared address",
+			sip:  "100.64.1.1",
+			want: []error{ErrPrivateRange},
+		},
+		{
+			name: "Should not warn for a public IP next to the carrier-grade NAT block",
+			sip:  "100.128.0.1",
+			want: nil,
+		},
+		{
+			name: "Should warn for a range overlapping a private block",
+			sip:  "9.255.255.0-10.0.0.1",
+			want: []error{ErrPrivateRange},
+		},
+		{
+			name: "Should warn for the whole address space",
+			sip:  "0.0.0.0-255.255.255.255",
+			want: []error{ErrPrivateRange, ErrWholeAddressSpace},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sip.Warnings(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Warnings()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
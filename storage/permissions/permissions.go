@@ -37,6 +37,41 @@ func (s SignedPermission) String() string {
 	return string(s)
 }
 
+// Name returns the name of the operation the permission grants, for example,
+// "Delete version", or an empty string if the permission is unknown.
+func (s SignedPermission) Name() string {
+	return signedPermissionMap()[s].OpName
+}
+
+// NameFor returns the name of the operation the permission grants in the kind
+// of SAS, as some letters grant different operations per service, or an empty
+// string if the permission is unknown. For example, Permissions is named
+// "Process" for queue and account SAS.
+func (s SignedPermission) NameFor(kind Kind) string {
+	if name, ok := kindPermissionMap()[kind][s]; ok {
+		return name
+	}
+
+	return s.Name()
+}
+
+// Kind is the kind of SAS permissions are granted by, which is determined by
+// the signed resource, or the service for resources without one.
+type Kind int
+
+const (
+	// KindBlob is a SAS for a blob, container or directory.
+	KindBlob Kind = iota
+	// KindFile is a SAS for a file or share.
+	KindFile
+	// KindQueue is a SAS for a queue.
+	KindQueue
+	// KindTable is a SAS for a table.
+	KindTable
+	// KindAccount is an account SAS.
+	KindAccount
+)
+
 const (
 	Read            SignedPermission = "r"
 	Add             SignedPermission = "a"
@@ -51,6 +86,12 @@ const (
 	Execute         SignedPermission = "e"
 	Ownership       SignedPermission = "o"
 	Permissions     SignedPermission = "p"
+
+	// The following permissions aren't parsed by Parse, which only handles
+	// blob permissions, and are named for each Kind of SAS by NameFor.
+	Update                SignedPermission = "u"
+	SetImmutabilityPolicy SignedPermission = "i"
+	FilterByTags          SignedPermission = "f"
 )
 
 type SignedPermissions struct {
@@ -226,3 +267,32 @@ func signedPermissionMap() map[SignedPermission]signedPermissionSpec {
 		},
 	}
 }
+
+// kindPermissionMap returns the names of the operations granted by
+// permissions which differ from the blob permissions above for a kind of SAS.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-service-sas#permissions-for-a-directory-container-or-blob
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-service-sas#permissions-for-a-queue
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-service-sas#permissions-for-a-table
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-account-sas#account-sas-permissions-by-operation
+func kindPermissionMap() map[Kind]map[SignedPermission]string {
+	return map[Kind]map[SignedPermission]string{
+		KindBlob: {
+			SetImmutabilityPolicy: "Set immutability policy",
+			FilterByTags:          "Filter by tags",
+		},
+		KindQueue: {
+			Update:      "Update",
+			Permissions: "Process",
+		},
+		KindTable: {
+			Update: "Update",
+		},
+		KindAccount: {
+			Update:                "Update",
+			Permissions:           "Process",
+			SetImmutabilityPolicy: "Set immutability policy",
+			FilterByTags:          "Filter by tags",
+		},
+	}
+}
@@ -118,6 +118,20 @@ func Parse(protocols string) (spr SignedProtocols) {
 	return spr
 }
 
+// ParseDevelopmentStorage returns a valid set of protocols for development
+// storage, such as Azurite. In addition to the values accepted by Parse, HTTP
+// only (http) is permitted, as development storage is served over HTTP.
+func ParseDevelopmentStorage(protocols string) (spr SignedProtocols) {
+	if strings.ToLower(strings.TrimSpace(protocols)) == HTTP.String() {
+		return SignedProtocols{
+			hasValues: true,
+			protocols: [numProtocols]SignedProtocol{1: HTTP},
+		}
+	}
+
+	return Parse(protocols)
+}
+
 func protocolMap() map[SignedProtocol]int {
 	return map[SignedProtocol]int{
 		HTTPS: 0,
@@ -0,0 +1,82 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	// Standard Library Imports
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+)
+
+// DefaultClockSkew is the amount of time Microsoft recommends backdating the
+// signed start by to tolerate clock skew between clients and Azure Storage.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/storage/common/storage-sas-overview#best-practices-when-using-sas
+const DefaultClockSkew = 15 * time.Minute
+
+// parseSignedTime parses a signed start or signed expiry in the provided
+// location. Generic time parsing errors are overwritten with the provided
+// invalid format error, so callers know which field is at fault.
+func parseSignedTime(value string, loc *time.Location, errInvalidFormat error) (time.Time, error) {
+	t, err := aztime.ParseISO8601DateTimeInLocation(value, loc)
+	if err != nil {
+		switch err {
+		case aztime.ErrDateTimeEmpty,
+			aztime.ErrDateTimeNonExistent,
+			aztime.ErrDateTimeAmbiguous:
+			// Bubble up internal known errors.
+			return time.Time{}, err
+
+		default:
+			// Overwrite time parsing errors as an invalid format error.
+			return time.Time{}, errInvalidFormat
+		}
+	}
+
+	return t, nil
+}
+
+// resolveSignedStart backdates the signed start by the provided skew if a
+// signed start hasn't been specified, then validates that the resulting
+// validity window is usable.
+//
+// This is shared between SAS types so that every SAS constructor applies the
+// same start/expiry rules.
+func resolveSignedStart(
+	start time.Time,
+	expiry time.Time,
+	skew time.Duration,
+	now time.Time,
+) (time.Time, error) {
+	if start.IsZero() && skew > 0 {
+		// Azure Storage only supports second level precision for the signed
+		// start, so drop anything finer to keep the token stable.
+		start = now.Add(-skew).Truncate(time.Second)
+	}
+
+	if !expiry.After(now) {
+		return start, ErrExpiryInPast
+	}
+
+	if !start.IsZero() && !start.Before(expiry) {
+		return start, ErrStartAfterExpiry
+	}
+
+	return start, nil
+}
@@ -18,13 +18,15 @@ package storage
 
 import (
 	// Standard Library Imports
-	"encoding/base64"
+	"context"
 	"net/url"
+	"strings"
 	"time"
 
 	// Internal Imports
 	"github.com/matthewhartstonge/sassy/storage/aztime"
 	"github.com/matthewhartstonge/sassy/storage/crypto"
+	"github.com/matthewhartstonge/sassy/storage/endpoints"
 	"github.com/matthewhartstonge/sassy/storage/ips"
 	"github.com/matthewhartstonge/sassy/storage/permissions"
 	"github.com/matthewhartstonge/sassy/storage/protocols"
@@ -48,37 +50,97 @@ func NewAccountSAS(
 	accountSAS *AccountSAS,
 	err error,
 ) {
-	storageKeyBytes, err := base64.StdEncoding.DecodeString(storageAccountKey)
+	key, err := crypto.DecodeKey(storageAccountKey)
 	if err != nil {
 		return nil, ErrDecodingStorageAccountKey
 	}
 
-	sv, ok := versions.Parse(signedVersion)
-	if !ok {
-		return nil, ErrInvalidVersion
+	return NewAccountSASWithKey(
+		storageAccountName,
+		key,
+		signedVersion,
+		signedServices,
+		signedResourceTypes,
+		signedPermissions,
+		signedExpiry,
+		opts...,
+	)
+}
+
+// NewAccountSASWithKey provides a way to generate an account based Shared
+// Access Signature (SAS) token from an already decoded storage account key.
+// The account SAS takes ownership of the key, destroying it if the account SAS
+// can't be created, or when the account SAS is closed.
+func NewAccountSASWithKey(
+	storageAccountName string,
+	key *crypto.Key,
+	signedVersion string,
+	signedServices string,
+	signedResourceTypes string,
+	signedPermissions string,
+	signedExpiry string,
+	opts ...AccountSASOption,
+) (
+	accountSAS *AccountSAS,
+	err error,
+) {
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrDecodingStorageAccountKey
 	}
 
-	se, err := aztime.ParseISO8601DateTime(signedExpiry)
+	accountSAS, err = NewAccountSASWithSigner(
+		storageAccountName,
+		signer,
+		signedVersion,
+		signedServices,
+		signedResourceTypes,
+		signedPermissions,
+		signedExpiry,
+		opts...,
+	)
 	if err != nil {
-		switch err {
-		case aztime.ErrDateTimeEmpty:
-			// Bubble up internal known errors.
-			return nil, err
+		key.Destroy()
+		return nil, err
+	}
 
-		default:
-			// Overwrite time parsing errors as an invalid format error.
-			return nil, ErrInvalidExpiryDateFormat
-		}
+	return accountSAS, nil
+}
+
+// NewAccountSASWithSigner provides a way to generate an account based Shared
+// Access Signature (SAS) token, where signing is delegated to the provided
+// signer, so that the storage account key doesn't need to be held in process
+// memory.
+func NewAccountSASWithSigner(
+	storageAccountName string,
+	signer crypto.Signer,
+	signedVersion string,
+	signedServices string,
+	signedResourceTypes string,
+	signedPermissions string,
+	signedExpiry string,
+	opts ...AccountSASOption,
+) (
+	accountSAS *AccountSAS,
+	err error,
+) {
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	sv, ok := versions.Parse(signedVersion)
+	if !ok {
+		return nil, ErrInvalidVersion
 	}
 
 	accountSAS = &AccountSAS{
 		storageAccountName:  storageAccountName,
-		storageAccountKey:   storageKeyBytes,
+		signer:              signer,
 		SignedVersion:       sv,
 		SignedServices:      services.Parse(signedServices),
 		SignedResourceTypes: resourcetypes.Parse(signedResourceTypes),
 		SignedPermission:    permissions.Parse(sv, signedPermissions),
-		SignedExpiry:        se,
+		location:            time.Local,
 	}
 
 	// Inject optional fields
@@ -88,6 +150,47 @@ func NewAccountSAS(
 		}
 	}
 
+	// Signed start and expiry are parsed once all options have been applied,
+	// so they are interpreted in the requested timezone, regardless of the
+	// order options were provided in.
+	accountSAS.SignedExpiry, err = parseSignedTime(
+		signedExpiry,
+		accountSAS.location,
+		ErrInvalidExpiryDateFormat,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if accountSAS.signedStart != "" {
+		accountSAS.SignedStart, err = parseSignedTime(
+			accountSAS.signedStart,
+			accountSAS.location,
+			ErrInvalidStartDateFormat,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if accountSAS.signedProtocols != "" {
+		if accountSAS.isDevelopmentStorage() {
+			accountSAS.SignedProtocol = protocols.ParseDevelopmentStorage(accountSAS.signedProtocols)
+		} else {
+			accountSAS.SignedProtocol = protocols.Parse(accountSAS.signedProtocols)
+		}
+	}
+
+	accountSAS.SignedStart, err = resolveSignedStart(
+		accountSAS.SignedStart,
+		accountSAS.SignedExpiry,
+		accountSAS.clockSkew,
+		time.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return accountSAS, nil
 }
 
@@ -103,30 +206,95 @@ func WithAPIVersion(apiVersion string) AccountSASOption {
 
 func WithSignedStart(startDateTime string) AccountSASOption {
 	return func(options *AccountSAS) error {
-		st, err := aztime.ParseISO8601DateTime(startDateTime)
+		if strings.TrimSpace(startDateTime) == "" {
+			return aztime.ErrDateTimeEmpty
+		}
+
+		// Parsing is deferred until the timezone is known.
+		options.signedStart = startDateTime
+
+		return nil
+	}
+}
+
+// WithEndpoints sets the service endpoints used to build connection strings,
+// for example, to target a sovereign cloud or custom domain. Defaults to the
+// Azure public cloud endpoints for the storage account.
+func WithEndpoints(ep *endpoints.Endpoints) AccountSASOption {
+	return func(options *AccountSAS) error {
+		if ep == nil {
+			return ErrEndpointsRequired
+		}
+
+		options.endpoints = ep
+
+		return nil
+	}
+}
+
+// WithTimeZone sets the IANA timezone, for example, "Pacific/Auckland", that
+// signed start and signed expiry are interpreted in when they don't include a
+// timezone designator. Defaults to the local timezone.
+func WithTimeZone(zone string) AccountSASOption {
+	return func(options *AccountSAS) error {
+		loc, err := aztime.LoadLocation(zone)
 		if err != nil {
-			switch err {
-			case aztime.ErrDateTimeEmpty:
-				// Bubble up internal known errors.
-				return err
+			return ErrInvalidTimeZone
+		}
 
-			default:
-				// Overwrite time parsing errors as an invalid format error.
-				return ErrInvalidStartDateFormat
-			}
+		options.location = loc
+
+		return nil
+	}
+}
+
+// WithLocation sets the location that signed start and signed expiry are
+// interpreted in when they don't include a timezone designator. Defaults to
+// the local timezone.
+func WithLocation(loc *time.Location) AccountSASOption {
+	return func(options *AccountSAS) error {
+		if loc == nil {
+			return ErrInvalidTimeZone
 		}
 
-		options.SignedStart = st
+		options.location = loc
 
 		return nil
 	}
 }
 
+// WithClockSkew backdates the signed start by the given skew if a signed start
+// has not been explicitly provided. This allows a freshly minted token to be
+// used straight away by clients whose clocks are slightly out of sync with
+// Azure Storage.
+//
+// Microsoft recommends a skew of 15 minutes, which is available as
+// DefaultClockSkew.
+func WithClockSkew(skew time.Duration) AccountSASOption {
+	return func(options *AccountSAS) error {
+		if skew < 0 {
+			return ErrInvalidClockSkew
+		}
+
+		options.clockSkew = skew
+
+		return nil
+	}
+}
+
+// WithSignedIP restricts the SAS to a single IPv4 address, a dash separated
+// IPv4 address range, or an IPv4 CIDR, for example, "10.1.0.0/22".
 func WithSignedIP(ip string) AccountSASOption {
 	return func(options *AccountSAS) error {
-		sip, ok := ips.Parse(ip)
-		if !ok {
-			return ErrInvalidIPv4Format
+		sip, err := ips.FromString(ip)
+		if err != nil {
+			switch err {
+			case ips.ErrIPv6NotSupported:
+				return ErrIPv6NotSupported
+
+			default:
+				return ErrInvalidIPv4Format
+			}
 		}
 
 		options.SignedIP = sip
@@ -136,7 +304,9 @@ func WithSignedIP(ip string) AccountSASOption {
 
 func WithSignedProtocols(signedProtocols string) AccountSASOption {
 	return func(options *AccountSAS) error {
-		options.SignedProtocol = protocols.Parse(signedProtocols)
+		// Parsing is deferred until the target is known, as HTTP only is
+		// permitted for development storage.
+		options.signedProtocols = signedProtocols
 
 		return nil
 	}
@@ -144,7 +314,12 @@ func WithSignedProtocols(signedProtocols string) AccountSASOption {
 
 type AccountSAS struct {
 	storageAccountName  string
-	storageAccountKey   []byte
+	signer              crypto.Signer
+	clockSkew           time.Duration
+	location            *time.Location
+	signedStart         string
+	endpoints           *endpoints.Endpoints
+	signedProtocols     string
 	APIVersion          string
 	SignedVersion       versions.SignedVersion
 	SignedServices      services.SignedServices
@@ -156,9 +331,28 @@ type AccountSAS struct {
 	SignedProtocol      protocols.SignedProtocols
 }
 
+// Close destroys the key material held by the signer, if the signer supports
+// it. The account SAS can't be used to sign tokens once closed.
+func (o *AccountSAS) Close() error {
+	return crypto.Close(o.signer)
+}
+
+// Warnings returns any concerns with the account SAS which, while valid, are
+// unlikely to be what was intended, for example, a signed IP covering private
+// addresses that Azure Storage never sees as a source address.
+func (o *AccountSAS) Warnings() []error {
+	return o.SignedIP.Warnings()
+}
+
 // Token generates and signs an account based storage SAS token based on the
 // stored configuration.
-func (o AccountSAS) Token() string {
+func (o *AccountSAS) Token() (string, error) {
+	return o.TokenContext(context.Background())
+}
+
+// TokenContext generates and signs an account based storage SAS token based
+// on the stored configuration. The context is passed through to the signer.
+func (o *AccountSAS) TokenContext(ctx context.Context) (string, error) {
 	params := &url.Values{}
 	if o.APIVersion != "" {
 		params.Add("api-version", o.APIVersion)
@@ -185,14 +379,102 @@ func (o AccountSAS) Token() string {
 
 	o.SignedIP.SetParam(params)
 	o.SignedProtocol.SetParam(params)
-	o.signPayload(params)
+	if err := o.signPayload(ctx, params); err != nil {
+		return "", err
+	}
 
-	return params.Encode()
+	return params.Encode(), nil
+}
+
+// Endpoints returns the configured service endpoints, defaulting to the Azure
+// public cloud endpoints for the storage account, or the development storage
+// endpoints for the well-known development storage account.
+func (o *AccountSAS) Endpoints() (*endpoints.Endpoints, error) {
+	if o.endpoints != nil {
+		return o.endpoints, nil
+	}
+
+	if o.storageAccountName == DevelopmentStorageAccountName {
+		return endpoints.NewDevelopmentStorage()
+	}
+
+	return endpoints.New(o.storageAccountName)
+}
+
+// isDevelopmentStorage reports whether the account SAS targets development
+// storage.
+func (o *AccountSAS) isDevelopmentStorage() bool {
+	if o.endpoints != nil {
+		return o.endpoints.IsDevelopmentStorage()
+	}
+
+	return o.storageAccountName == DevelopmentStorageAccountName
+}
+
+// ConnectionString generates a signed token and returns it as a SAS connection
+// string, containing the endpoints for each of the signed services.
+func (o *AccountSAS) ConnectionString() (string, error) {
+	return o.ConnectionStringContext(context.Background())
+}
+
+// ConnectionStringContext generates a signed token and returns it as a SAS
+// connection string, containing the endpoints for each of the signed services.
+// The context is passed through to the signer.
+func (o *AccountSAS) ConnectionStringContext(ctx context.Context) (string, error) {
+	ep, err := o.Endpoints()
+	if err != nil {
+		return "", err
+	}
+
+	token, err := o.TokenContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return SASConnectionString(ep, o.SignedServices, token)
+}
+
+// SignURL generates a signed token and merges it into the query parameters of
+// the provided URL, for example, a URL built with Endpoints().BlobURL.
+func (o *AccountSAS) SignURL(u *url.URL) (*url.URL, error) {
+	return o.SignURLContext(context.Background(), u)
+}
+
+// SignURLContext generates a signed token and merges it into the query
+// parameters of the provided URL. The context is passed through to the signer.
+func (o *AccountSAS) SignURLContext(ctx context.Context, u *url.URL) (*url.URL, error) {
+	token, err := o.TokenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoints.Sign(u, token)
 }
 
 // signPayload generates the required HMAC-SHA256 signature and binds it into
 // the provided url params.
-func (o AccountSAS) signPayload(params *url.Values) {
+func (o *AccountSAS) signPayload(ctx context.Context, params *url.Values) error {
+	stringToSign := AccountStringToSign(o.storageAccountName, *params)
+
+	// Compute HMAC-S256 signature
+	signature, err := crypto.SignWithExpiry(ctx, o.signer, []byte(stringToSign), o.SignedExpiry)
+	if err != nil {
+		return err
+	}
+
+	params.Add("sig", signature)
+
+	return nil
+}
+
+// encryptionScopeVersion is the first version to sign the signed encryption
+// scope of an account SAS.
+const encryptionScopeVersion = "2020-12-06"
+
+// AccountStringToSign returns the string-to-sign of an account SAS for the
+// storage account, built from the SAS's query parameters, which is useful for
+// verifying a token or debugging authorization failures.
+func AccountStringToSign(storageAccountName string, query url.Values) string {
 	// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/create-account-sas#constructing-the-signature-string
 	// To construct the signature string for an account SAS, first construct the
 	// string-to-sign from the fields comprising the request, then encode the
@@ -202,22 +484,21 @@ func (o AccountSAS) signPayload(params *url.Values) {
 	// Note:
 	// - Fields included in the string-to-sign must be UTF-8, URL-decoded.
 	//   - Go by default uses utf-8 encoded strings.
-	//   - The `String()` methods ensure no URL encoding is taking place.
-	stringToSign := o.storageAccountName + "\n" +
-		o.SignedPermission.String() + "\n" +
-		o.SignedServices.String() + "\n" +
-		o.SignedResourceTypes.String() + "\n" +
-		aztime.ToString(o.SignedStart) + "\n" +
-		aztime.ToString(o.SignedExpiry) + "\n" +
-		o.SignedIP.String() + "\n" +
-		o.SignedProtocol.String() + "\n" +
-		o.SignedVersion.String() + "\n"
+	//   - url.Values holds URL-decoded values.
+	// - An optional field that isn't provided is signed as an empty string.
+	stringToSign := storageAccountName + "\n" +
+		query.Get("sp") + "\n" +
+		query.Get("ss") + "\n" +
+		query.Get("srt") + "\n" +
+		query.Get(aztime.ParamKeySignedStart) + "\n" +
+		query.Get(aztime.ParamKeySignedExpiry) + "\n" +
+		query.Get("sip") + "\n" +
+		query.Get("spr") + "\n" +
+		query.Get("sv") + "\n"
 
-	// Compute HMAC-S256 signature
-	signature := crypto.HMACSHA256(
-		o.storageAccountKey,
-		[]byte(stringToSign),
-	)
+	if query.Get("sv") >= encryptionScopeVersion {
+		stringToSign += query.Get("ses") + "\n"
+	}
 
-	params.Add("sig", signature)
+	return stringToSign
 }
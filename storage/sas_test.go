@@ -0,0 +1,382 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+	"github.com/matthewhartstonge/sassy/storage/ips"
+)
+
+func TestResolveSignedStart(t *testing.T) {
+	now := time.Date(2021, 10, 21, 12, 30, 45, 500, time.UTC)
+	start := time.Date(2021, 10, 21, 9, 0, 0, 0, time.UTC)
+	expiry := time.Date(2021, 10, 22, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		start     time.Time
+		expiry    time.Time
+		skew      time.Duration
+		wantStart time.Time
+		wantErr   error
+	}{
+		{
+			name:   "Should leave the signed start unset without a skew",
+			expiry: expiry,
+		},
+		{
+			name:      "Should backdate the signed start by the skew, truncated to the second",
+			expiry:    expiry,
+			skew:      DefaultClockSkew,
+			wantStart: time.Date(2021, 10, 21, 12, 15, 45, 0, time.UTC),
+		},
+		{
+			name:      "Should not backdate an explicit signed start",
+			start:     start,
+			expiry:    expiry,
+			skew:      DefaultClockSkew,
+			wantStart: start,
+		},
+		{
+			name:      "Should allow an explicit signed start in the past",
+			start:     start,
+			expiry:    expiry,
+			wantStart: start,
+		},
+		{
+			name:    "Should not allow a signed expiry in the past",
+			expiry:  now.Add(-time.Second),
+			wantErr: ErrExpiryInPast,
+		},
+		{
+			name:    "Should not allow a signed expiry of now",
+			expiry:  now,
+			wantErr: ErrExpiryInPast,
+		},
+		{
+			name:    "Should not allow a signed expiry in the past with a skew",
+			expiry:  now.Add(-time.Second),
+			skew:    DefaultClockSkew,
+			wantErr: ErrExpiryInPast,
+		},
+		{
+			name:    "Should not allow an explicit signed start after the signed expiry",
+			start:   expiry.Add(time.Hour),
+			expiry:  expiry,
+			wantErr: ErrStartAfterExpiry,
+		},
+		{
+			name:    "Should not allow an explicit signed start equal to the signed expiry",
+			start:   expiry,
+			expiry:  expiry,
+			skew:    DefaultClockSkew,
+			wantErr: ErrStartAfterExpiry,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStart, err := resolveSignedStart(tt.start, tt.expiry, tt.skew, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolveSignedStart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !gotStart.Equal(tt.wantStart) {
+				t.Errorf("resolveSignedStart() gotStart = %v, want %v", gotStart, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestWithClockSkew(t *testing.T) {
+	expiry := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name      string
+		start     string
+		skew      time.Duration
+		wantStart bool
+		wantErr   error
+	}{
+		{
+			name: "Should not set a signed start without a skew",
+		},
+		{
+			name:      "Should set a signed start with a skew",
+			skew:      DefaultClockSkew,
+			wantStart: true,
+		},
+		{
+			name:      "Should prefer an explicit signed start over a skew",
+			start:     "2021-10-21T09:00:00Z",
+			skew:      DefaultClockSkew,
+			wantStart: true,
+		},
+		{
+			name:    "Should not allow a negative skew",
+			skew:    -time.Minute,
+			wantErr: ErrInvalidClockSkew,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []AccountSASOption{WithClockSkew(tt.skew)}
+			if tt.start != "" {
+				opts = append(opts, WithSignedStart(tt.start))
+			}
+
+			before := time.Now()
+			sas, err := NewAccountSAS("sassy", "c2Fzc3k=", "2020-10-02", "b", "o", "r", expiry, opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAccountSAS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			defer sas.Close()
+
+			if got := !sas.SignedStart.IsZero(); got != tt.wantStart {
+				t.Fatalf("NewAccountSAS() SignedStart = %v, wantStart %v", sas.SignedStart, tt.wantStart)
+			}
+
+			switch {
+			case tt.start != "":
+				if want := time.Date(2021, 10, 21, 9, 0, 0, 0, time.UTC); !sas.SignedStart.Equal(want) {
+					t.Errorf("NewAccountSAS() SignedStart = %v, want %v", sas.SignedStart, want)
+				}
+
+			case tt.wantStart:
+				earliest := before.Add(-tt.skew).Truncate(time.Second)
+				if sas.SignedStart.Before(earliest) || sas.SignedStart.After(time.Now().Add(-tt.skew)) {
+					t.Errorf("NewAccountSAS() SignedStart = %v, want about %v", sas.SignedStart, earliest)
+				}
+			}
+		})
+	}
+}
+
+func TestAccountSAS_Warnings(t *testing.T) {
+	expiry := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		ip   string
+		want []error
+	}{
+		{
+			name: "Should not warn without a signed IP",
+			want: nil,
+		},
+		{
+			name: "Should not warn for a public IP",
+			ip:   "1.1.1.1",
+			want: nil,
+		},
+		{
+			name: "Should warn for a private CIDR",
+			ip:   "10.0.0.0/8",
+			want: []error{ips.ErrPrivateRange},
+		},
+		{
+			name: "Should warn for the whole address space",
+			ip:   "0.0.0.0/0",
+			want: []error{ips.ErrPrivateRange, ips.ErrWholeAddressSpace},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []AccountSASOption
+			if tt.ip != "" {
+				opts = append(opts, WithSignedIP(tt.ip))
+			}
+
+			sas, err := NewAccountSAS("sassy", "c2Fzc3k=", "2020-10-02", "b", "o", "r", expiry, opts...)
+			if err != nil {
+				t.Fatalf("NewAccountSAS() error = %v", err)
+			}
+			defer sas.Close()
+
+			if got := sas.Warnings(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Warnings()\ngot:  = %v\nwant: %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAccountSASWithSigner(t *testing.T) {
+	const (
+		accountName = "sassy"
+		accountKey  = "c2Fzc3ktc3RvcmFnZS1rZXktMDEyMzQ1Njc4OWFiY2RlZg=="
+		expiry      = "2099-12-12T10:00:00Z"
+	)
+	errSigner := errors.New("signer unavailable")
+
+	key, err := base64.StdEncoding.DecodeString(accountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := NewAccountSAS(accountName, accountKey, "2020-10-02", "bf", "sco", "rl", expiry, WithSignedStart("2021-12-12T10:00:00Z"))
+	if err != nil {
+		t.Fatalf("NewAccountSAS() error = %v", err)
+	}
+	defer want.Close()
+
+	wantToken, err := want.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		signer    crypto.Signer
+		wantToken string
+		wantErr   error
+	}{
+		{
+			name: "Should produce the same token as the storage account key",
+			signer: crypto.SignerFunc(func(ctx context.Context, message []byte) (string, error) {
+				return crypto.HMACSHA256(key, message), nil
+			}),
+			wantToken: wantToken,
+		},
+		{
+			name: "Should return signer errors",
+			signer: crypto.SignerFunc(func(ctx context.Context, message []byte) (string, error) {
+				return "", errSigner
+			}),
+			wantErr: errSigner,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sas, err := NewAccountSASWithSigner(accountName, tt.signer, "2020-10-02", "bf", "sco", "rl", expiry, WithSignedStart("2021-12-12T10:00:00Z"))
+			if err != nil {
+				t.Fatalf("NewAccountSASWithSigner() error = %v", err)
+			}
+
+			gotToken, err := sas.Token()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Token() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotToken != tt.wantToken {
+				t.Errorf("Token()\ngot:  = %v\nwant: %v\n", gotToken, tt.wantToken)
+			}
+		})
+	}
+
+	t.Run("Should require a signer", func(t *testing.T) {
+		_, err := NewAccountSASWithSigner(accountName, nil, "2020-10-02", "bf", "sco", "rl", expiry)
+		if !errors.Is(err, ErrSignerRequired) {
+			t.Errorf("NewAccountSASWithSigner() error = %v, wantErr %v", err, ErrSignerRequired)
+		}
+	})
+}
+
+func TestAccountSAS_Token_StringToSign(t *testing.T) {
+	const expiry = "2099-12-12T10:00:00Z"
+
+	tests := []struct {
+		name string
+		opts []AccountSASOption
+		want string
+	}{
+		{
+			name: "Should sign an empty signed start if not provided",
+			opts: []AccountSASOption{WithSignedProtocols("https")},
+			want: "sassy\nrl\nbf\nsco\n\n2099-12-12T10:00:00Z\n\nhttps\n2020-10-02\n",
+		},
+		{
+			name: "Should sign the signed start if provided",
+			opts: []AccountSASOption{WithSignedProtocols("https"), WithSignedStart("2021-12-12T10:00:00Z")},
+			want: "sassy\nrl\nbf\nsco\n2021-12-12T10:00:00Z\n2099-12-12T10:00:00Z\n\nhttps\n2020-10-02\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			signer := crypto.SignerFunc(func(ctx context.Context, message []byte) (string, error) {
+				got = string(message)
+				return "c2Fzc3k=", nil
+			})
+
+			sas, err := NewAccountSASWithSigner("sassy", signer, "2020-10-02", "bf", "sco", "rl", expiry, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewAccountSASWithSigner() error = %v", err)
+			}
+
+			if _, err = sas.Token(); err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Token() string-to-sign\ngot:  = %q\nwant: %q\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountStringToSign(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Should sign each field URL-decoded",
+			query: "sv=2020-10-02&ss=bf&srt=sco&sp=rl&se=2099-12-12T10%3A00%3A00Z&sip=168.1.5.60-168.1.5.70&spr=https",
+			want:  "sassy\nrl\nbf\nsco\n\n2099-12-12T10:00:00Z\n168.1.5.60-168.1.5.70\nhttps\n2020-10-02\n",
+		},
+		{
+			name:  "Should not sign the signed encryption scope before 2020-12-06",
+			query: "sv=2020-10-02&ss=b&srt=o&sp=r&st=2021-12-12T10%3A00%3A00Z&se=2099-12-12T10%3A00%3A00Z&ses=scope",
+			want:  "sassy\nr\nb\no\n2021-12-12T10:00:00Z\n2099-12-12T10:00:00Z\n\n\n2020-10-02\n",
+		},
+		{
+			name:  "Should sign the signed encryption scope from 2020-12-06",
+			query: "sv=2020-12-06&ss=b&srt=o&sp=r&se=2099-12-12T10%3A00%3A00Z&ses=scope",
+			want:  "sassy\nr\nb\no\n\n2099-12-12T10:00:00Z\n\n\n2020-12-06\nscope\n",
+		},
+		{
+			name:  "Should sign an empty signed encryption scope from 2020-12-06",
+			query: "sv=2020-12-06&ss=b&srt=o&sp=r&se=2099-12-12T10%3A00%3A00Z",
+			want:  "sassy\nr\nb\no\n\n2099-12-12T10:00:00Z\n\n\n2020-12-06\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			if got := AccountStringToSign("sassy", query); got != tt.want {
+				t.Errorf("AccountStringToSign()\ngot:  = %q\nwant: %q\n", got, tt.want)
+			}
+		})
+	}
+}
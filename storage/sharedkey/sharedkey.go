@@ -0,0 +1,313 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sharedkey provides Shared Key and Shared Key Lite authorization of
+// Azure Storage REST requests, for the Blob, Queue, File and Table services,
+// as an alternative to SAS tokens when the account key is held.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+package sharedkey
+
+import (
+	// Standard Library Imports
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/internal/canonical"
+	"github.com/matthewhartstonge/sassy/storage/aztime"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+	"github.com/matthewhartstonge/sassy/storage/endpoints"
+	"github.com/matthewhartstonge/sassy/storage/versions"
+)
+
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderDate          = "Date"
+	HeaderMSDate        = "x-ms-date"
+	HeaderMSVersion     = "x-ms-version"
+
+	canonicalizedHeaderPrefix = "x-ms-"
+	compQueryParam            = "comp"
+)
+
+// Scheme specifies the Shared Key authorization scheme, as the Table service
+// and the Lite variants each use their own string-to-sign format.
+type Scheme int
+
+const (
+	// SharedKey is the Shared Key scheme for the Blob, Queue and File
+	// services.
+	SharedKey Scheme = iota
+	// SharedKeyLite is the Shared Key Lite scheme for the Blob, Queue and
+	// File services.
+	SharedKeyLite
+	// TableSharedKey is the Shared Key scheme for the Table service.
+	TableSharedKey
+	// TableSharedKeyLite is the Shared Key Lite scheme for the Table service.
+	TableSharedKeyLite
+)
+
+// String implements Stringer, returning the scheme's authorization type.
+func (s Scheme) String() string {
+	switch s {
+	case SharedKeyLite, TableSharedKeyLite:
+		return "SharedKeyLite"
+
+	default:
+		return "SharedKey"
+	}
+}
+
+var (
+	ErrAccountNameEmpty          = errors.New("storage account name must not be empty")
+	ErrSignerRequired            = errors.New("a signer must be provided to sign requests")
+	ErrDecodingStorageAccountKey = errors.New("error decoding storage account key, must be base64 encoded")
+	ErrInvalidVersion            = errors.New("error parsing storage service version")
+	ErrInvalidScheme             = errors.New("invalid Shared Key authorization scheme")
+)
+
+// Signer authorizes Azure Storage REST requests with Shared Key.
+type Signer struct {
+	accountName string
+	signer      crypto.Signer
+	endpoints   *endpoints.Endpoints
+	version     versions.SignedVersion
+	scheme      Scheme
+	now         func() time.Time
+}
+
+// Option configures a Signer.
+type Option func(s *Signer) error
+
+// NewSigner returns a Shared Key request signer for the storage account,
+// where signing is delegated to the provided signer, for example, a
+// crypto.Keyring.
+func NewSigner(storageAccountName string, signer crypto.Signer, opts ...Option) (*Signer, error) {
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	ep, err := endpoints.New(storageAccountName)
+	if err != nil {
+		return nil, ErrAccountNameEmpty
+	}
+
+	s := &Signer{
+		accountName: ep.AccountName,
+		signer:      signer,
+		endpoints:   ep,
+		version:     versions.Latest,
+		scheme:      SharedKey,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// NewSignerFromKey returns a Shared Key request signer for the storage account
+// which signs with the base64 encoded storage account key.
+func NewSignerFromKey(storageAccountName string, storageAccountKey string, opts ...Option) (*Signer, error) {
+	key, err := crypto.DecodeKey(storageAccountKey)
+	if err != nil {
+		return nil, ErrDecodingStorageAccountKey
+	}
+
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrDecodingStorageAccountKey
+	}
+
+	s, err := NewSigner(storageAccountName, signer, opts...)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close destroys the storage account key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// WithVersion sets the x-ms-version header sent on requests that don't
+// already specify one. Defaults to the latest version.
+func WithVersion(version string) Option {
+	return func(s *Signer) error {
+		sv, ok := versions.Parse(version)
+		if !ok || sv == versions.VAll {
+			return ErrInvalidVersion
+		}
+
+		s.version = sv
+
+		return nil
+	}
+}
+
+// WithScheme sets the authorization scheme. Defaults to SharedKey, which
+// supports the Blob, Queue and File services. Use TableSharedKey or
+// TableSharedKeyLite for the Table service.
+func WithScheme(scheme Scheme) Option {
+	return func(s *Signer) error {
+		switch scheme {
+		case SharedKey, SharedKeyLite, TableSharedKey, TableSharedKeyLite:
+			s.scheme = scheme
+
+			return nil
+
+		default:
+			return ErrInvalidScheme
+		}
+	}
+}
+
+// SignRequest authorizes the request in place, setting the x-ms-date and
+// x-ms-version headers if they have not been provided, then setting the
+// Authorization header. The request's context is passed through to the
+// signer.
+func (s *Signer) SignRequest(req *http.Request) error {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	if req.Header.Get(HeaderMSDate) == "" && req.Header.Get(HeaderDate) == "" {
+		req.Header.Set(HeaderMSDate, aztime.ToRFC1123(s.now()))
+	}
+
+	if req.Header.Get(HeaderMSVersion) == "" {
+		req.Header.Set(HeaderMSVersion, s.version.String())
+	}
+
+	stringToSign := s.StringToSign(req)
+	signature, err := s.signer.Sign(req.Context(), []byte(stringToSign))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderAuthorization, s.scheme.String()+" "+s.accountName+":"+signature)
+
+	return nil
+}
+
+// StringToSign returns the string-to-sign for the request under the signer's
+// scheme, which is useful for debugging authorization failures.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#constructing-the-signature-string
+func (s *Signer) StringToSign(req *http.Request) string {
+	switch s.scheme {
+	case SharedKeyLite:
+		// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#blob-queue-and-file-services-shared-key-lite-authorization
+		return strings.ToUpper(req.Method) + "\n" +
+			req.Header.Get("Content-MD5") + "\n" +
+			req.Header.Get("Content-Type") + "\n" +
+			date(req, false) + "\n" +
+			canonicalizedHeaders(req.Header) +
+			s.liteCanonicalizedResource(req.URL)
+
+	case TableSharedKey:
+		// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#table-service-shared-key-authorization
+		return strings.ToUpper(req.Method) + "\n" +
+			req.Header.Get("Content-MD5") + "\n" +
+			req.Header.Get("Content-Type") + "\n" +
+			date(req, true) + "\n" +
+			s.liteCanonicalizedResource(req.URL)
+
+	case TableSharedKeyLite:
+		// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#table-service-shared-key-lite-authorization
+		return date(req, true) + "\n" +
+			s.liteCanonicalizedResource(req.URL)
+
+	default:
+		// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#blob-queue-and-file-services-shared-key-authorization
+		return strings.ToUpper(req.Method) + "\n" +
+			req.Header.Get("Content-Encoding") + "\n" +
+			req.Header.Get("Content-Language") + "\n" +
+			canonical.ContentLength(req) + "\n" +
+			req.Header.Get("Content-MD5") + "\n" +
+			req.Header.Get("Content-Type") + "\n" +
+			date(req, false) + "\n" +
+			req.Header.Get("If-Modified-Since") + "\n" +
+			req.Header.Get("If-Match") + "\n" +
+			req.Header.Get("If-None-Match") + "\n" +
+			req.Header.Get("If-Unmodified-Since") + "\n" +
+			req.Header.Get("Range") + "\n" +
+			canonicalizedHeaders(req.Header) +
+			s.canonicalizedResource(req.URL)
+	}
+}
+
+// date returns the date to include in the string-to-sign.
+//
+// The Table service signs the x-ms-date header in place of the Date header
+// when provided. The Blob, Queue and File services instead sign x-ms-date as
+// part of the canonicalized headers, leaving the date empty.
+func date(req *http.Request, table bool) string {
+	msDate := req.Header.Get(HeaderMSDate)
+	switch {
+	case msDate == "":
+		return req.Header.Get(HeaderDate)
+
+	case table:
+		return msDate
+
+	default:
+		return ""
+	}
+}
+
+// canonicalizedResource returns the canonicalized resource, including query
+// parameters, for the 2009-09-19 and later Shared Key format.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#shared-key-format-for-2009-09-19-and-later
+func (s *Signer) canonicalizedResource(u *url.URL) string {
+	return s.endpoints.CanonicalizedResource(u) + canonical.Query(u.Query())
+}
+
+// liteCanonicalizedResource returns the canonicalized resource used by the
+// Shared Key Lite and Table service formats, which only includes the comp
+// query parameter.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#shared-key-lite-and-table-service-format-for-2009-09-19-and-later
+func (s *Signer) liteCanonicalizedResource(u *url.URL) string {
+	resource := s.endpoints.CanonicalizedResource(u)
+	for name, values := range u.Query() {
+		if strings.EqualFold(name, compQueryParam) && len(values) > 0 {
+			return resource + "?" + compQueryParam + "=" + values[0]
+		}
+	}
+
+	return resource
+}
+
+// canonicalizedHeaders returns the lowercased, sorted x-ms- headers, each
+// terminated by a new line.
+//
+// Refer: https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key#constructing-the-canonicalized-headers-string
+func canonicalizedHeaders(header http.Header) string {
+	return canonical.Headers(header, canonicalizedHeaderPrefix)
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sharedkey
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// developmentStorageAccountKey is the well-known development storage key,
+// which is used so the expected signatures can be reproduced independently.
+const developmentStorageAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+func TestSigner_SignRequest(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name              string
+		scheme            Scheme
+		accountName       string
+		method            string
+		url               string
+		body              string
+		headers           map[string]string
+		wantStringToSign  string
+		wantAuthorization string
+	}{
+		{
+			name:        "Should sign a request with query parameters and x-ms- headers",
+			accountName: "sassy",
+			method:      http.MethodGet,
+			url:         "https://sassy.blob.core.windows.net/container?restype=container&comp=list&Include=snapshots&include=metadata",
+			headers: map[string]string{
+				"X-Ms-Client-Request-Id": "  abc    def ",
+				"User-Agent":             "sassy",
+			},
+			wantStringToSign: "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+				"x-ms-client-request-id:abc def\n" +
+				"x-ms-date:Sun, 12 Dec 2021 10:10:10 GMT\n" +
+				"x-ms-version:2020-10-02\n" +
+				"/sassy/container\ncomp:list\ninclude:metadata,snapshots\nrestype:container",
+			wantAuthorization: "SharedKey sassy:oxxr2SJT0FC/hDhDyhDGbnSIwW3TIxzUL3peKeeHrdw=",
+		},
+		{
+			name:        "Should sign a path-style development storage request with content",
+			accountName: "devstoreaccount1",
+			method:      http.MethodPut,
+			url:         "http://127.0.0.1:10000/devstoreaccount1/container/my%20blob.txt",
+			body:        "hello sassy",
+			headers: map[string]string{
+				"Content-Type":   "text/plain; charset=UTF-8",
+				"x-ms-blob-type": "BlockBlob",
+			},
+			wantStringToSign: "PUT\n\n\n11\n\ntext/plain; charset=UTF-8\n\n\n\n\n\n\n" +
+				"x-ms-blob-type:BlockBlob\n" +
+				"x-ms-date:Sun, 12 Dec 2021 10:10:10 GMT\n" +
+				"x-ms-version:2020-10-02\n" +
+				"/devstoreaccount1/devstoreaccount1/container/my%20blob.txt",
+			wantAuthorization: "SharedKey devstoreaccount1:eGZNebOPH4C41PVlutWqXQsuFXGVS6UT5xitwKgX0WI=",
+		},
+		{
+			name:        "Should sign a Shared Key Lite request",
+			scheme:      SharedKeyLite,
+			accountName: "sassy",
+			method:      http.MethodGet,
+			url:         "https://sassy.blob.core.windows.net/container?restype=container&comp=list",
+			wantStringToSign: "GET\n\n\n\n" +
+				"x-ms-date:Sun, 12 Dec 2021 10:10:10 GMT\n" +
+				"x-ms-version:2020-10-02\n" +
+				"/sassy/container?comp=list",
+			wantAuthorization: "SharedKeyLite sassy:V2GGE4ziRTghTy9F1AyjME/YbCFA4ngwH5EcDYkIV0s=",
+		},
+		{
+			name:        "Should sign a Table Shared Key request with x-ms-date in place of Date",
+			scheme:      TableSharedKey,
+			accountName: "sassy",
+			method:      http.MethodPost,
+			url:         "https://sassy.table.core.windows.net/Tables",
+			body:        `{"TableName":"mytable"}`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+				"Date":         "Mon, 13 Dec 2021 10:10:10 GMT",
+				"x-ms-date":    "Sun, 12 Dec 2021 10:10:10 GMT",
+			},
+			wantStringToSign:  "POST\n\napplication/json\nSun, 12 Dec 2021 10:10:10 GMT\n/sassy/Tables",
+			wantAuthorization: "SharedKey sassy:+3bWV1hdx2bxdvdB1LfhGCe37/BjLEy6frd/4w9BAGk=",
+		},
+		{
+			name:        "Should sign a Table Shared Key Lite request with the Date header",
+			scheme:      TableSharedKeyLite,
+			accountName: "sassy",
+			method:      http.MethodGet,
+			url:         "https://sassy.table.core.windows.net/mytable()?comp=acl&timeout=30",
+			headers: map[string]string{
+				"Date": "Sun, 12 Dec 2021 10:10:10 GMT",
+			},
+			wantStringToSign:  "Sun, 12 Dec 2021 10:10:10 GMT\n/sassy/mytable()?comp=acl",
+			wantAuthorization: "SharedKeyLite sassy:K4kaRT3+q5jZDtbZCNeUhI/XBNYPnBs6Euv/hUv12Fw=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSignerFromKey(tt.accountName, developmentStorageAccountKey, WithScheme(tt.scheme))
+			if err != nil {
+				t.Fatalf("NewSignerFromKey() unexpected error: %v", err)
+			}
+			signer.now = now
+
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body == "" {
+				req, _ = http.NewRequest(tt.method, tt.url, nil)
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if err = signer.SignRequest(req); err != nil {
+				t.Fatalf("SignRequest() unexpected error: %v", err)
+			}
+
+			if got := signer.StringToSign(req); got != tt.wantStringToSign {
+				t.Errorf("StringToSign()\ngot:  = %q\nwant: %q\n", got, tt.wantStringToSign)
+			}
+			if got := req.Header.Get(HeaderAuthorization); got != tt.wantAuthorization {
+				t.Errorf("SignRequest() authorization\ngot:  = %v\nwant: %v\n", got, tt.wantAuthorization)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sharedkey
+
+import (
+	// Standard Library Imports
+	"net/http"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/transport"
+)
+
+// Transport is a http.RoundTripper which authorizes each request with Shared
+// Key before passing it on to the base round tripper.
+type Transport = transport.Transport
+
+// NewTransport returns a Shared Key authorizing round tripper. If base is nil,
+// http.DefaultTransport is used.
+func NewTransport(signer *Signer, base http.RoundTripper) *Transport {
+	return transport.New(signer, base)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package transport provides a http.RoundTripper which authorizes each request
+// with a RequestSigner, as used by the Shared Key, Cosmos DB, HMAC-SHA256,
+// Batch and Log Analytics signers.
+package transport
+
+import (
+	// Standard Library Imports
+	"net/http"
+)
+
+// RequestSigner authorizes HTTP requests, typically by setting the
+// Authorization header.
+type RequestSigner interface {
+	SignRequest(req *http.Request) error
+}
+
+// RequestSignerFunc enables an ordinary function to be used as a
+// RequestSigner.
+type RequestSignerFunc func(req *http.Request) error
+
+// SignRequest implements RequestSigner.
+func (f RequestSignerFunc) SignRequest(req *http.Request) error {
+	return f(req)
+}
+
+// Transport is a http.RoundTripper which authorizes each request with the
+// signer before passing it on to the base round tripper.
+type Transport struct {
+	// Signer authorizes requests.
+	Signer RequestSigner
+	// Base is the underlying round tripper. Defaults to
+	// http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// New returns an authorizing round tripper. If base is nil,
+// http.DefaultTransport is used.
+func New(signer RequestSigner, base http.RoundTripper) *Transport {
+	return &Transport{
+		Signer: signer,
+		Base:   base,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The request is cloned before being
+// signed, as round trippers must not modify the provided request. The clone
+// shares the request's body, which is closed if signing fails.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	if err := t.Signer.SignRequest(signed); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	return t.base().RoundTrip(signed)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testAuthorization = "SharedKey sassy:c2lnbmF0dXJl"
+
+// roundTripFunc adapts a function to a http.RoundTripper.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// closeRecorder records whether a request body has been closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	errSigner := errors.New("signer unavailable")
+
+	tests := []struct {
+		name    string
+		signErr error
+		wantErr error
+	}{
+		{
+			name: "Should sign a clone of the request, keeping the body",
+		},
+		{
+			name:    "Should close the body and return signer errors",
+			signErr: errSigner,
+			wantErr: errSigner,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &closeRecorder{Reader: strings.NewReader("payload")}
+			req, err := http.NewRequest(http.MethodPut, "https://sassy.blob.core.windows.net/container/blob", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("x-ms-version", "2020-10-02")
+
+			signer := RequestSignerFunc(func(req *http.Request) error {
+				if tt.signErr != nil {
+					return tt.signErr
+				}
+
+				req.Header.Set("Authorization", testAuthorization)
+				return nil
+			})
+
+			var sent *http.Request
+			var sentBody string
+			base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				sent = req
+				b, err := io.ReadAll(req.Body)
+				sentBody = string(b)
+
+				return &http.Response{StatusCode: http.StatusCreated, Body: http.NoBody, Request: req}, err
+			})
+
+			res, err := New(signer, base).RoundTrip(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RoundTrip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := req.Header.Get("Authorization"); got != "" {
+				t.Errorf("RoundTrip() modified the provided request, Authorization = %v", got)
+			}
+
+			if tt.wantErr != nil {
+				if sent != nil {
+					t.Errorf("RoundTrip() sent a request which failed to sign")
+				}
+				if !body.closed {
+					t.Errorf("RoundTrip() didn't close the body of a request which failed to sign")
+				}
+
+				return
+			}
+
+			if res.StatusCode != http.StatusCreated {
+				t.Errorf("RoundTrip() status\ngot:  = %v\nwant: %v\n", res.StatusCode, http.StatusCreated)
+			}
+			if sent == req {
+				t.Errorf("RoundTrip() sent the provided request, rather than a clone")
+			}
+			if got := sent.Header.Get("Authorization"); got != testAuthorization {
+				t.Errorf("RoundTrip() Authorization\ngot:  = %v\nwant: %v\n", got, testAuthorization)
+			}
+			if got := sent.Header.Get("x-ms-version"); got != "2020-10-02" {
+				t.Errorf("RoundTrip() x-ms-version\ngot:  = %v\nwant: %v\n", got, "2020-10-02")
+			}
+			if sentBody != "payload" {
+				t.Errorf("RoundTrip() body\ngot:  = %v\nwant: %v\n", sentBody, "payload")
+			}
+		})
+	}
+}
+
+func TestTransport_base(t *testing.T) {
+	if got := New(nil, nil).base(); got != http.DefaultTransport {
+		t.Errorf("base()\ngot:  = %v\nwant: http.DefaultTransport\n", got)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webpubsub
+
+import (
+	// Standard Library Imports
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/connstr"
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+// Connection string keys.
+// Refer: https://docs.microsoft.com/en-us/azure/azure-signalr/concept-connection-string
+const (
+	ConnectionStringEndpoint  = "Endpoint"
+	ConnectionStringAccessKey = "AccessKey"
+	ConnectionStringVersion   = "Version"
+	ConnectionStringPort      = "Port"
+)
+
+const (
+	// SupportedVersion is the only connection string version supported.
+	SupportedVersion = "1.0"
+
+	// signalRHostSuffix identifies SignalR Service endpoints.
+	signalRHostSuffix = ".service.signalr.net"
+)
+
+var (
+	ErrInvalidEndpoint    = errors.New("endpoint must be an absolute http or https URL")
+	ErrUnsupportedVersion = errors.New("unsupported connection string version, must be 1.0")
+	ErrInvalidPort        = errors.New("port must be a number between 1 and 65535")
+	ErrHubEmpty           = errors.New("hub must not be empty")
+)
+
+// ConnectionString holds a parsed Web PubSub or SignalR Service connection
+// string.
+type ConnectionString struct {
+	// Endpoint is the service endpoint, including the port if provided.
+	Endpoint  *url.URL
+	AccessKey *crypto.Key
+	Version   string
+	// Service is SignalR if the endpoint is a SignalR Service endpoint,
+	// otherwise WebPubSub.
+	Service Service
+}
+
+// ParseConnectionString parses a Web PubSub or SignalR Service connection
+// string in the form Endpoint=...;AccessKey=...;Version=1.0;.
+//
+// Errors are returned as a *connstr.Error, naming the malformed part of the
+// connection string.
+func ParseConnectionString(connectionString string) (*ConnectionString, error) {
+	values, err := connstr.Parse(
+		connectionString,
+		ConnectionStringEndpoint,
+		ConnectionStringAccessKey,
+		ConnectionStringVersion,
+		ConnectionStringPort,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = values.Require(ConnectionStringEndpoint, ConnectionStringAccessKey); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(values.Get(ConnectionStringEndpoint))
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+		return nil, &connstr.Error{Key: ConnectionStringEndpoint, Err: ErrInvalidEndpoint}
+	}
+	endpoint.Path = ""
+
+	version := values.Get(ConnectionStringVersion)
+	if values.Has(ConnectionStringVersion) && version != SupportedVersion {
+		return nil, &connstr.Error{Key: ConnectionStringVersion, Err: ErrUnsupportedVersion}
+	}
+
+	if values.Has(ConnectionStringPort) {
+		port, err := strconv.Atoi(values.Get(ConnectionStringPort))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, &connstr.Error{Key: ConnectionStringPort, Err: ErrInvalidPort}
+		}
+		endpoint.Host = net.JoinHostPort(endpoint.Hostname(), strconv.Itoa(port))
+	}
+
+	cs := &ConnectionString{
+		Endpoint: endpoint,
+		Version:  version,
+		Service:  WebPubSub,
+	}
+	if strings.HasSuffix(strings.ToLower(endpoint.Hostname()), signalRHostSuffix) {
+		cs.Service = SignalR
+	}
+
+	if cs.AccessKey, err = crypto.NewKey([]byte(values.Get(ConnectionStringAccessKey))); err != nil {
+		return nil, &connstr.Error{Key: ConnectionStringAccessKey, Err: ErrAccessKeyEmpty}
+	}
+
+	return cs, nil
+}
+
+// Signer returns a token signer for the connection string's service and
+// access key. The signer shares the connection string's key, so closing the
+// signer destroys the key.
+func (c *ConnectionString) Signer() (*Signer, error) {
+	signer, err := crypto.NewKeySigner(c.AccessKey)
+	if err != nil {
+		return nil, ErrAccessKeyEmpty
+	}
+
+	return NewSigner(signer, WithService(c.Service))
+}
+
+// NewSignerFromConnectionString returns a token signer for the access key in
+// the connection string, along with the parsed connection string which
+// provides the hub URLs tokens are issued for.
+func NewSignerFromConnectionString(connectionString string) (*Signer, *ConnectionString, error) {
+	cs, err := ParseConnectionString(connectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := newSignerWithKey(cs.AccessKey, WithService(cs.Service))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, cs, nil
+}
+
+// ClientAudience returns the hub URL client tokens must be issued for, being
+// {endpoint}/client/hubs/{hub} for Web PubSub and {endpoint}/client/?hub={hub}
+// for SignalR.
+func (c *ConnectionString) ClientAudience(hub string) (string, error) {
+	u, err := c.clientURL(hub)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// ClientURL returns the URL a client connects to the hub with, carrying the
+// token in the access_token query parameter. Web PubSub clients connect with
+// a WebSocket, so the URL uses the ws or wss scheme.
+func (c *ConnectionString) ClientURL(hub string, token string) (*url.URL, error) {
+	u, err := c.clientURL(hub)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Service == WebPubSub {
+		u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	}
+
+	query := u.Query()
+	query.Set(ParamAccessToken, token)
+	u.RawQuery = query.Encode()
+
+	return u, nil
+}
+
+// clientURL returns the hub's client URL.
+func (c *ConnectionString) clientURL(hub string) (*url.URL, error) {
+	if strings.TrimSpace(hub) == "" {
+		return nil, ErrHubEmpty
+	}
+
+	u := *c.Endpoint
+	switch c.Service {
+	case SignalR:
+		u.Path = "/client/"
+		u.RawQuery = url.Values{"hub": {strings.ToLower(hub)}}.Encode()
+
+	default:
+		u.Path = "/client/hubs/" + hub
+	}
+
+	return &u, nil
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package webpubsub provides issuance and verification of the HS256 access
+// key JWTs accepted by Azure Web PubSub and Azure SignalR Service.
+//
+// Refer: https://docs.microsoft.com/en-us/azure/azure-web-pubsub/howto-generate-client-access-url
+package webpubsub
+
+import (
+	// Standard Library Imports
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	// Internal Imports
+	"github.com/matthewhartstonge/sassy/storage/crypto"
+)
+
+const (
+	// ParamAccessToken is the query parameter clients send the token in.
+	ParamAccessToken = "access_token"
+
+	// jwtHeader is the base64url encoded {"alg":"HS256","typ":"JWT"} header.
+	jwtHeader = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9"
+)
+
+// Service specifies which service tokens are issued for, as the services
+// name the user ID claim differently.
+type Service int
+
+const (
+	// WebPubSub is Azure Web PubSub.
+	WebPubSub Service = iota
+	// SignalR is Azure SignalR Service.
+	SignalR
+)
+
+// String implements Stringer.
+func (s Service) String() string {
+	switch s {
+	case SignalR:
+		return "SignalR"
+
+	default:
+		return "WebPubSub"
+	}
+}
+
+var (
+	ErrSignerRequired    = errors.New("a signer must be provided to sign tokens")
+	ErrAccessKeyEmpty    = errors.New("access key must not be empty")
+	ErrInvalidService    = errors.New("invalid service, must be WebPubSub or SignalR")
+	ErrAudienceEmpty     = errors.New("audience must not be empty")
+	ErrExpiryRequired    = errors.New("token expiry must be provided")
+	ErrInvalidToken      = errors.New("invalid HS256 JWT")
+	ErrSignatureMismatch = errors.New("token signature does not match")
+	ErrAudienceMismatch  = errors.New("token audience does not match")
+	ErrTokenExpired      = errors.New("token has expired")
+)
+
+// Claims are the claims of an access key JWT.
+type Claims struct {
+	// Audience is the hub URL the token grants access to.
+	Audience string
+	// UserID identifies the connecting user. It is sent as the sub claim to
+	// Web PubSub and the nameid claim to SignalR.
+	UserID string
+	// Roles are the permissions granted, for example,
+	// webpubsub.joinLeaveGroup.
+	Roles []string
+	// Groups are the Web PubSub groups the connection joins on connect.
+	Groups []string
+	// IssuedAt is when the token was issued, at a resolution of seconds.
+	IssuedAt time.Time
+	// Expiry is when the token stops being valid, at a resolution of seconds.
+	Expiry time.Time
+}
+
+// jwtClaims is the JSON representation of Claims.
+type jwtClaims struct {
+	Audience string   `json:"aud"`
+	IssuedAt int64    `json:"iat"`
+	Expiry   int64    `json:"exp"`
+	Subject  string   `json:"sub,omitempty"`
+	NameID   string   `json:"nameid,omitempty"`
+	Roles    []string `json:"role,omitempty"`
+	Groups   []string `json:"webpubsub.group,omitempty"`
+}
+
+// Signer issues and verifies access key JWTs.
+type Signer struct {
+	signer  crypto.Signer
+	service Service
+	now     func() time.Time
+}
+
+// Option configures a Signer.
+type Option func(s *Signer) error
+
+// NewSigner returns a token signer, where signing is delegated to the
+// provided signer. Tokens are issued for Web PubSub unless WithService is
+// provided.
+//
+// Access keys are used as is, rather than being base64 decoded, so the signer
+// must sign with the key's raw bytes.
+func NewSigner(signer crypto.Signer, opts ...Option) (*Signer, error) {
+	if signer == nil {
+		return nil, ErrSignerRequired
+	}
+
+	s := &Signer{
+		signer:  signer,
+		service: WebPubSub,
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// NewSignerFromKey returns a token signer which signs with the access key.
+func NewSignerFromKey(accessKey string, opts ...Option) (*Signer, error) {
+	key, err := crypto.NewKey([]byte(accessKey))
+	if err != nil {
+		return nil, ErrAccessKeyEmpty
+	}
+
+	return newSignerWithKey(key, opts...)
+}
+
+// newSignerWithKey returns a token signer which takes ownership of the key,
+// destroying it on error.
+func newSignerWithKey(key *crypto.Key, opts ...Option) (*Signer, error) {
+	signer, err := crypto.NewKeySigner(key)
+	if err != nil {
+		return nil, ErrAccessKeyEmpty
+	}
+
+	s, err := NewSigner(signer, opts...)
+	if err != nil {
+		key.Destroy()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// WithService sets the service tokens are issued for. Defaults to WebPubSub.
+func WithService(service Service) Option {
+	return func(s *Signer) error {
+		switch service {
+		case WebPubSub, SignalR:
+			s.service = service
+
+			return nil
+
+		default:
+			return ErrInvalidService
+		}
+	}
+}
+
+// Close destroys the access key, if held in memory.
+func (s *Signer) Close() error {
+	return crypto.Close(s.signer)
+}
+
+// Token returns a HS256 JWT for the claims. If IssuedAt is zero, it is set to
+// the current time.
+func (s *Signer) Token(ctx context.Context, claims Claims) (string, error) {
+	if strings.TrimSpace(claims.Audience) == "" {
+		return "", ErrAudienceEmpty
+	}
+
+	if claims.Expiry.IsZero() {
+		return "", ErrExpiryRequired
+	}
+
+	if claims.IssuedAt.IsZero() {
+		claims.IssuedAt = s.now()
+	}
+
+	c := jwtClaims{
+		Audience: claims.Audience,
+		IssuedAt: claims.IssuedAt.Unix(),
+		Expiry:   claims.Expiry.Unix(),
+		Roles:    claims.Roles,
+		Groups:   claims.Groups,
+	}
+	if s.service == SignalR {
+		c.NameID = claims.UserID
+	} else {
+		c.Subject = claims.UserID
+	}
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := crypto.SignWithExpiry(ctx, s.signer, []byte(unsigned), claims.Expiry)
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + toBase64URL(signature), nil
+}
+
+// Verify checks the token's signature, audience and expiry, returning its
+// claims. ErrSignatureMismatch, ErrAudienceMismatch or ErrTokenExpired is
+// returned if the token isn't valid for the audience.
+func (s *Signer) Verify(ctx context.Context, token string, audience string) (*Claims, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var h struct {
+		Algorithm string `json:"alg"`
+	}
+	if err = json.Unmarshal(header, &h); err != nil || h.Algorithm != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	_, ok, err := crypto.Verify(ctx, s.signer, []byte(parts[0]+"."+parts[1]), base64.StdEncoding.EncodeToString(signature))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSignatureMismatch
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var c jwtClaims
+	if err = json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims{
+		Audience: c.Audience,
+		UserID:   c.Subject,
+		Roles:    c.Roles,
+		Groups:   c.Groups,
+		IssuedAt: time.Unix(c.IssuedAt, 0).UTC(),
+		Expiry:   time.Unix(c.Expiry, 0).UTC(),
+	}
+	if c.NameID != "" {
+		claims.UserID = c.NameID
+	}
+
+	if claims.Audience != audience {
+		return nil, ErrAudienceMismatch
+	}
+
+	if !s.now().Before(claims.Expiry) {
+		return nil, ErrTokenExpired
+	}
+
+	return claims, nil
+}
+
+// toBase64URL converts a base64 encoded signature to the unpadded base64url
+// encoding JWTs use.
+func toBase64URL(signature string) string {
+	return strings.NewReplacer("+", "-", "/", "_", "=", "").Replace(signature)
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright © 2021 Matthew Hartstonge <matt@mykro.co.nz>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webpubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matthewhartstonge/sassy/connstr"
+)
+
+const (
+	// testAccessKey is deliberately base64 shaped, as access keys must be
+	// used as is, rather than decoded.
+	testAccessKey = "c2Fzc3ktYWNjZXNzLWtleQ=="
+	testAudience  = "https://sassy.webpubsub.azure.com/client/hubs/dashboard"
+	testToken     = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." +
+		"eyJhdWQiOiJodHRwczovL3Nhc3N5LndlYnB1YnN1Yi5henVyZS5jb20vY2xpZW50L2h1YnMvZGFzaGJvYXJkIiwiaWF0IjoxNjM5MzAwMjEwLCJleHAiOjE2MzkzMDM4MTAsInN1YiI6InVzZXItMSIsInJvbGUiOlsid2VicHVic3ViLmpvaW5MZWF2ZUdyb3VwLmRhc2hib2FyZCJdLCJ3ZWJwdWJzdWIuZ3JvdXAiOlsiZGFzaGJvYXJkIl19." +
+		"aiF0DYfIDcwoCYMTTO7EbfoQ-3-4nMOJ0bWTfhpuYq0"
+)
+
+var testExpiry = time.Date(2021, 12, 12, 10, 10, 10, 0, time.UTC)
+
+func TestSigner_Token(t *testing.T) {
+	s, cs, err := NewSignerFromConnectionString("Endpoint=https://sassy.webpubsub.azure.com;AccessKey=" + testAccessKey + ";Version=1.0;")
+	if err != nil {
+		t.Fatalf("NewSignerFromConnectionString() error = %v", err)
+	}
+	defer s.Close()
+
+	audience, err := cs.ClientAudience("dashboard")
+	if err != nil {
+		t.Fatalf("ClientAudience() error = %v", err)
+	}
+
+	got, err := s.Token(context.Background(), Claims{
+		Audience: audience,
+		UserID:   "user-1",
+		Roles:    []string{"webpubsub.joinLeaveGroup.dashboard"},
+		Groups:   []string{"dashboard"},
+		IssuedAt: testExpiry.Add(-time.Hour),
+		Expiry:   testExpiry,
+	})
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	if got != testToken {
+		t.Errorf("Token()\ngot:  = %v\nwant: %v\n", got, testToken)
+	}
+
+	u, err := cs.ClientURL("dashboard", got)
+	if err != nil {
+		t.Fatalf("ClientURL() error = %v", err)
+	}
+
+	wantURL := "wss://sassy.webpubsub.azure.com/client/hubs/dashboard?access_token=" + testToken
+	if u.String() != wantURL {
+		t.Errorf("ClientURL()\ngot:  = %v\nwant: %v\n", u.String(), wantURL)
+	}
+}
+
+func TestSigner_Verify(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		token    string
+		audience string
+		now      time.Time
+		wantErr  error
+	}{
+		{
+			name:     "Should verify a token",
+			key:      testAccessKey,
+			token:    testToken,
+			audience: testAudience,
+			now:      testExpiry.Add(-time.Minute),
+		},
+		{
+			name:     "Should error on an expired token",
+			key:      testAccessKey,
+			token:    testToken,
+			audience: testAudience,
+			now:      testExpiry,
+			wantErr:  ErrTokenExpired,
+		},
+		{
+			name:     "Should error on a token for another hub",
+			key:      testAccessKey,
+			token:    testToken,
+			audience: "https://sassy.webpubsub.azure.com/client/hubs/admin",
+			now:      testExpiry.Add(-time.Minute),
+			wantErr:  ErrAudienceMismatch,
+		},
+		{
+			name:     "Should error on a token signed with another key",
+			key:      "another-key",
+			token:    testToken,
+			audience: testAudience,
+			now:      testExpiry.Add(-time.Minute),
+			wantErr:  ErrSignatureMismatch,
+		},
+		{
+			name:     "Should error on an unsigned token",
+			key:      testAccessKey,
+			token:    "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.e30.",
+			audience: testAudience,
+			now:      testExpiry.Add(-time.Minute),
+			wantErr:  ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSignerFromKey(tt.key)
+			if err != nil {
+				t.Fatalf("NewSignerFromKey() error = %v", err)
+			}
+			defer s.Close()
+			s.now = func() time.Time { return tt.now }
+
+			got, err := s.Verify(context.Background(), tt.token, tt.audience)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify()\ngot:  = %v\nwant: %v\n", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.UserID != "user-1" || !got.Expiry.Equal(testExpiry) {
+				t.Errorf("Verify()\ngot:  = %+v\nwant: user-1 expiring %v\n", *got, testExpiry)
+			}
+		})
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		name             string
+		connectionString string
+		wantAudience     string
+		wantErrKey       string
+		wantErr          error
+	}{
+		{
+			name:             "Should parse a Web PubSub connection string",
+			connectionString: "Endpoint=https://sassy.webpubsub.azure.com;AccessKey=" + testAccessKey + ";Version=1.0;",
+			wantAudience:     testAudience,
+		},
+		{
+			name:             "Should parse a SignalR connection string, lowercasing the hub",
+			connectionString: "Endpoint=https://sassy.service.signalr.net;AccessKey=" + testAccessKey + ";Version=1.0;",
+			wantAudience:     "https://sassy.service.signalr.net/client/?hub=dashboard",
+		},
+		{
+			name:             "Should include the port",
+			connectionString: "Endpoint=http://localhost;Port=8080;AccessKey=" + testAccessKey + ";Version=1.0;",
+			wantAudience:     "http://localhost:8080/client/hubs/dashboard",
+		},
+		{
+			name:             "Should error on an unsupported version",
+			connectionString: "Endpoint=https://sassy.webpubsub.azure.com;AccessKey=" + testAccessKey + ";Version=2.0;",
+			wantErrKey:       ConnectionStringVersion,
+			wantErr:          ErrUnsupportedVersion,
+		},
+		{
+			name:             "Should error on a missing access key",
+			connectionString: "Endpoint=https://sassy.webpubsub.azure.com;Version=1.0;",
+			wantErrKey:       ConnectionStringAccessKey,
+			wantErr:          connstr.ErrMissingKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionString(tt.connectionString)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseConnectionString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var csErr *connstr.Error
+				if !errors.As(err, &csErr) || csErr.Key != tt.wantErrKey {
+					t.Errorf("ParseConnectionString()\ngot:  = %v\nwant: key %v\n", err, tt.wantErrKey)
+				}
+				return
+			}
+
+			audience, err := got.ClientAudience("Dashboard")
+			if got.Service == WebPubSub {
+				audience, err = got.ClientAudience("dashboard")
+			}
+			if err != nil {
+				t.Fatalf("ClientAudience() error = %v", err)
+			}
+
+			if audience != tt.wantAudience {
+				t.Errorf("ClientAudience()\ngot:  = %v\nwant: %v\n", audience, tt.wantAudience)
+			}
+		})
+	}
+}